@@ -1,24 +1,51 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// User — пользователь и в API (server.User — псевдоним), и в таблице users.
+// Столбцы сопоставляются с полями по тегам db (reflection.StructMap).
 type User struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Age   int    `json:"age"`
+	ID        int       `json:"id" db:"id,pk"`
+	Name      string    `json:"name" db:"name"`
+	Email     string    `json:"email" db:"email"`
+	Age       int       `json:"age,omitempty" db:"age"`
+	Country   string    `json:"country,omitempty" db:"country"`
+	Active    bool      `json:"active" db:"active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Open открывает базу SQLite в файле path (":memory:" — в памяти). Схему
+// создают миграции: NewMigrator(db, Migrations).Up.
+func Open(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if path == ":memory:" {
+		// У каждого соединения была бы своя пустая база.
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
 
 func DemoDatabase() {
-	db, err := sql.Open("sqlite3", "./demo.db")
+	db, err := Open("./demo.db")
 	if err != nil {
 		log.Printf("Ошибка открытия БД: %v\n", err)
 		return
@@ -26,172 +53,159 @@ func DemoDatabase() {
 	defer db.Close()
 	defer os.Remove("./demo.db")
 	
-	createTableSQL := `CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		email TEXT UNIQUE NOT NULL,
-		age INTEGER
-	);`
-	
-	_, err = db.Exec(createTableSQL)
+	ctx := context.Background()
+	applied, err := NewMigrator(db, Migrations).Up(ctx)
 	if err != nil {
-		log.Printf("Ошибка создания таблицы: %v\n", err)
+		log.Printf("Ошибка миграции: %v\n", err)
 		return
 	}
-	fmt.Println("Таблица users создана")
-	
-	users := []User{
-		{Name: "Иван Петров", Email: "ivan@example.com", Age: 30},
-		{Name: "Мария Сидорова", Email: "maria@example.com", Age: 25},
-		{Name: "Петр Иванов", Email: "petr@example.com", Age: 35},
-	}
-	
-	for _, user := range users {
-		insertUser(db, user)
+	for _, m := range applied {
+		fmt.Printf("Применена миграция %s\n", m)
 	}
 	
-	fmt.Println("\nВсе пользователи:")
-	allUsers := getAllUsers(db)
-	for _, user := range allUsers {
-		fmt.Printf("  ID: %d, Имя: %s, Email: %s, Возраст: %d\n", 
-			user.ID, user.Name, user.Email, user.Age)
-	}
-	
-	fmt.Println("\nПользователь с ID=2:")
-	user, err := getUserByID(db, 2)
+	users, err := NewRepository[User](db, "users")
 	if err != nil {
-		log.Printf("Ошибка получения пользователя: %v\n", err)
-	} else {
-		fmt.Printf("  %+v\n", user)
+		log.Printf("Ошибка создания репозитория: %v\n", err)
+		return
 	}
 	
-	fmt.Println("\nОбновление пользователя ID=1:")
-	updateUser(db, 1, User{Name: "Иван Петров", Email: "ivan.new@example.com", Age: 31})
-	
-	fmt.Println("\nУдаление пользователя ID=3:")
-	deleteUser(db, 3)
+	if err := demoRepository(ctx, users); err != nil {
+		log.Printf("Ошибка: %v\n", err)
+		return
+	}
 	
 	fmt.Println("\nДемонстрация транзакции:")
-	demoTransaction(db)
+	demoTransaction(ctx, db, users)
 	
 	fmt.Println("\nЭкспорт в JSON:")
-	exportToJSON(db, "users.json")
+	if err := exportToJSON(ctx, users, "users.json"); err != nil {
+		log.Printf("Ошибка экспорта: %v\n", err)
+	}
 }
 
-func insertUser(db *sql.DB, user User) {
-	insertSQL := `INSERT INTO users (name, email, age) VALUES (?, ?, ?)`
-	result, err := db.Exec(insertSQL, user.Name, user.Email, user.Age)
-	if err != nil {
-		log.Printf("Ошибка вставки: %v\n", err)
-		return
+func demoRepository(ctx context.Context, users *Repository[User]) error {
+	now := time.Now()
+	for _, user := range []User{
+		{Name: "Иван Петров", Email: "ivan@example.com", Age: 30, Country: "Russia"},
+		{Name: "Мария Сидорова", Email: "maria@example.com", Age: 25, Country: "Russia"},
+		{Name: "Петр Иванов", Email: "petr@example.com", Age: 35, Country: "Belarus"},
+	} {
+		user.Active, user.CreatedAt, user.UpdatedAt = true, now, now
+		if err := users.Create(ctx, &user); err != nil {
+			return fmt.Errorf("вставка %s: %w", user.Email, err)
+		}
+		fmt.Printf("Вставлен пользователь с ID: %d\n", user.ID)
 	}
 	
-	id, _ := result.LastInsertId()
-	fmt.Printf("Вставлен пользователь с ID: %d\n", id)
-}
-
-func getAllUsers(db *sql.DB) []User {
-	rows, err := db.Query("SELECT id, name, email, age FROM users")
+	fmt.Println("\nВсе пользователи:")
+	all, err := users.List(ctx, ListOptions{OrderBy: []Order{Asc("id")}})
 	if err != nil {
-		log.Printf("Ошибка запроса: %v\n", err)
-		return nil
+		return err
 	}
-	defer rows.Close()
-	
-	var users []User
-	for rows.Next() {
-		var user User
-		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Age)
-		if err != nil {
-			log.Printf("Ошибка сканирования: %v\n", err)
-			continue
-		}
-		users = append(users, user)
+	for _, user := range all {
+		fmt.Printf("  ID: %d, Имя: %s, Email: %s, Возраст: %d\n", 
+			user.ID, user.Name, user.Email, user.Age)
 	}
 	
-	return users
-}
-
-func getUserByID(db *sql.DB, id int) (*User, error) {
-	query := "SELECT id, name, email, age FROM users WHERE id = ?"
-	row := db.QueryRow(query, id)
-	
-	var user User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Age)
+	fmt.Println("\nПользователи из России старше 26, по возрасту:")
+	adults, err := users.List(ctx, ListOptions{
+		Where:   []Cond{Eq("country", "Russia"), Gt("age", 26)},
+		OrderBy: []Order{Desc("age")},
+		Limit:   10,
+	})
 	if err != nil {
-		return nil, err
+		return err
+	}
+	for _, user := range adults {
+		fmt.Printf("  %s (%d)\n", user.Name, user.Age)
 	}
 	
-	return &user, nil
-}
-
-func updateUser(db *sql.DB, id int, user User) {
-	updateSQL := `UPDATE users SET name = ?, email = ?, age = ? WHERE id = ?`
-	_, err := db.Exec(updateSQL, user.Name, user.Email, user.Age, id)
+	fmt.Println("\nПользователь с ID=2:")
+	user, err := users.Get(ctx, 2)
 	if err != nil {
-		log.Printf("Ошибка обновления: %v\n", err)
-		return
+		return err
 	}
-	fmt.Printf("Пользователь ID=%d обновлен\n", id)
-}
-
-func deleteUser(db *sql.DB, id int) {
-	deleteSQL := `DELETE FROM users WHERE id = ?`
-	_, err := db.Exec(deleteSQL, id)
+	fmt.Printf("  %+v\n", user)
+	
+	fmt.Println("\nОбновление пользователя ID=1:")
+	user, err = users.Get(ctx, 1)
 	if err != nil {
-		log.Printf("Ошибка удаления: %v\n", err)
-		return
+		return err
 	}
-	fmt.Printf("Пользователь ID=%d удален\n", id)
-}
-
-func demoTransaction(db *sql.DB) {
-	tx, err := db.Begin()
-	if err != nil {
-		log.Printf("Ошибка начала транзакции: %v\n", err)
-		return
+	user.Email, user.Age, user.UpdatedAt = "ivan.new@example.com", 31, time.Now()
+	if err := users.Update(ctx, &user); err != nil {
+		return err
 	}
+	fmt.Printf("Пользователь ID=%d обновлен\n", user.ID)
 	
-	_, err = tx.Exec("INSERT INTO users (name, email, age) VALUES (?, ?, ?)", 
-		"Анна Кузнецова", "anna@example.com", 28)
-	if err != nil {
-		tx.Rollback()
-		log.Printf("Ошибка в транзакции: %v\n", err)
-		return
+	fmt.Println("\nУдаление пользователя ID=3:")
+	if err := users.Delete(ctx, 3); err != nil {
+		return err
 	}
+	fmt.Println("Пользователь ID=3 удален")
 	
-	_, err = tx.Exec("INSERT INTO users (name, email, age) VALUES (?, ?, ?)", 
-		"Дмитрий Смирнов", "dmitry@example.com", 32)
+	if err := users.Delete(ctx, 3); errors.Is(err, ErrNotFound) {
+		fmt.Println("Повторное удаление: пользователя уже нет")
+	}
+	return nil
+}
+
+func demoTransaction(ctx context.Context, db *sql.DB, users *Repository[User]) {
+	err := WithTx(ctx, db, nil, func(ctx context.Context, tx Querier) error {
+		users := users.With(tx)
+		now := time.Now()
+		for _, user := range []User{
+			{Name: "Анна Кузнецова", Email: "anna@example.com", Age: 28, Country: "Russia"},
+			{Name: "Дмитрий Смирнов", Email: "dmitry@example.com", Age: 32, Country: "Russia"},
+		} {
+			user.Active, user.CreatedAt, user.UpdatedAt = true, now, now
+			if err := users.Create(ctx, &user); err != nil {
+				return err
+			}
+		}
+		
+		// Вложенный WithTx — точка сохранения: его ошибка откатывает только
+		// его изменения, а внешняя транзакция продолжается.
+		err := WithTx(ctx, db, nil, func(ctx context.Context, tx Querier) error {
+			extra := User{Name: "Елена Волкова", Email: "elena@example.com", Age: 27, CreatedAt: now, UpdatedAt: now}
+			if err := users.With(tx).Create(ctx, &extra); err != nil {
+				return err
+			}
+			duplicate := User{Name: "Анна К.", Email: "anna@example.com", CreatedAt: now, UpdatedAt: now}
+			return users.With(tx).Create(ctx, &duplicate)
+		})
+		fmt.Printf("Вложенная транзакция откачена: %v\n", err)
+		return nil
+	})
 	if err != nil {
-		tx.Rollback()
 		log.Printf("Ошибка в транзакции: %v\n", err)
 		return
 	}
 	
-	err = tx.Commit()
+	n, err := users.Count(ctx, In("email", "anna@example.com", "dmitry@example.com", "elena@example.com"))
 	if err != nil {
-		log.Printf("Ошибка коммита: %v\n", err)
+		log.Printf("Ошибка подсчета: %v\n", err)
 		return
 	}
-	
-	fmt.Println("Транзакция успешно выполнена (2 пользователя добавлены)")
+	fmt.Printf("Транзакция успешно выполнена (добавлено пользователей: %d)\n", n)
 }
 
-func exportToJSON(db *sql.DB, filename string) {
-	users := getAllUsers(db)
-	
-	data, err := json.MarshalIndent(users, "", "  ")
+func exportToJSON(ctx context.Context, users *Repository[User], filename string) error {
+	all, err := users.List(ctx, ListOptions{})
 	if err != nil {
-		log.Printf("Ошибка маршалинга JSON: %v\n", err)
-		return
+		return err
 	}
 	
-	err = os.WriteFile(filename, data, 0644)
+	data, err := json.MarshalIndent(all, "", "  ")
 	if err != nil {
-		log.Printf("Ошибка записи файла: %v\n", err)
-		return
+		return err
 	}
 	
-	fmt.Printf("Данные экспортированы в %s\n", filename)
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return err
+	}
 	defer os.Remove(filename)
+	
+	fmt.Printf("Данные экспортированы в %s\n", filename)
+	return nil
 }
@@ -0,0 +1,321 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations — миграции схемы из migrations/, встроенные в бинарник.
+var Migrations = mustLoadMigrations(migrationFiles, "migrations")
+
+// Migration — шаг схемы: Up применяет его, Down откатывает.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Checksum — SHA-256 текста Up. Он сохраняется при применении: изменённая
+// после этого миграция на другой базе дала бы другую схему.
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrations читает из dir пары файлов <версия>_<имя>.up.sql и
+// <версия>_<имя>.down.sql и возвращает миграции по возрастанию версий.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		match := migrationFile.FindStringSubmatch(path.Base(file))
+		if match == nil {
+			return nil, fmt.Errorf("%s: want <version>_<name>.up.sql or .down.sql", file)
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%s: invalid version", file)
+		}
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("%s: version %d is also used by %s", file, version, m)
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both up and down files", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func mustLoadMigrations(fsys fs.FS, dir string) []Migration {
+	migrations, err := LoadMigrations(fsys, dir)
+	if err != nil {
+		panic("database: " + err.Error())
+	}
+	return migrations
+}
+
+// ChecksumError — применённая миграция с тех пор изменилась.
+type ChecksumError struct {
+	Migration Migration
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("database: migration %s was changed after it was applied", e.Migration)
+}
+
+// MigrationStatus — состояние миграции в базе.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator применяет миграции к базе. Применённые версии хранятся в таблице
+// schema_migrations вместе с контрольными суммами; каждая миграция
+// выполняется в своей транзакции вместе с записью о ней, так что упавшая
+// миграция не оставляет схему наполовину изменённой.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// applied читает schema_migrations и сверяет её с известными миграциями.
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT version, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var (
+			version int
+			a       appliedMigration
+		)
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]bool, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		if a, ok := applied[mig.Version]; ok && a.checksum != mig.Checksum() {
+			return nil, &ChecksumError{Migration: mig}
+		}
+	}
+	for version := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("database: applied migration %d is unknown to this build", version)
+		}
+	}
+	return applied, nil
+}
+
+// Status возвращает все миграции с отметкой о применении.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]MigrationStatus, len(m.migrations))
+	for i, mig := range m.migrations {
+		a, ok := applied[mig.Version]
+		status[i] = MigrationStatus{Migration: mig, Applied: ok, AppliedAt: a.appliedAt}
+	}
+	return status, nil
+}
+
+// Pending возвращает ещё не применённые миграции.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, s := range status {
+		if !s.Applied {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Version возвращает наибольшую применённую версию; 0 — пустая схема.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// Up применяет все недостающие миграции и возвращает их.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if len(m.migrations) == 0 {
+		return nil, nil
+	}
+	return m.To(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down откатывает последнюю применённую миграцию.
+func (m *Migrator) Down(ctx context.Context) ([]Migration, error) {
+	version, err := m.Version(ctx)
+	if err != nil || version == 0 {
+		return nil, err
+	}
+	target := 0
+	for _, mig := range m.migrations {
+		if mig.Version < version {
+			target = mig.Version
+		}
+	}
+	return m.To(ctx, target)
+}
+
+// To приводит схему к версии version: применяет недостающие миграции до неё
+// по возрастанию и откатывает более поздние по убыванию. 0 откатывает всё.
+// Возвращает выполненные шаги; при ошибке — уже выполненные до неё.
+func (m *Migrator) To(ctx context.Context, version int) ([]Migration, error) {
+	if version != 0 && !m.known(version) {
+		return nil, fmt.Errorf("database: unknown migration version %d", version)
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; ok && mig.Version > version {
+			if err := m.run(ctx, mig, false); err != nil {
+				return done, err
+			}
+			done = append(done, mig)
+		}
+	}
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; !ok && mig.Version <= version {
+			if err := m.run(ctx, mig, true); err != nil {
+				return done, err
+			}
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+func (m *Migrator) known(version int) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// run выполняет миграцию и запись о ней в одной транзакции.
+func (m *Migrator) run(ctx context.Context, mig Migration, up bool) (err error) {
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("database: migration %s %s: %w", mig, direction, err)
+		}
+	}()
+
+	return WithTx(ctx, m.db, nil, func(ctx context.Context, tx Querier) error {
+		if up {
+			if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx,
+				"INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				mig.Version, mig.Name, mig.Checksum(), time.Now().UTC())
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", mig.Version)
+		return err
+	})
+}
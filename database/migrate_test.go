@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// baselineSchema — таблица users в том виде, в каком её создавали до
+// появления миграций.
+const baselineSchema = `CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL,
+	age INTEGER
+);`
+
+// TestMigrateBaselineDatabase: первая миграция принимает базу со старой
+// схемой, и её строки читаются уже по новой.
+func TestMigrateBaselineDatabase(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(filepath.Join(t.TempDir(), "baseline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(baselineSchema); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO users (name, email, age) VALUES ('Ann', 'ann@example.com', 30), ('Bob', 'bob@example.com', NULL)`); err != nil {
+		t.Fatal(err)
+	}
+
+	migrator := NewMigrator(db, Migrations)
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if version, err := migrator.Version(ctx); err != nil || version != Migrations[len(Migrations)-1].Version {
+		t.Fatalf("Version() = %d, %v; want %d", version, err, Migrations[len(Migrations)-1].Version)
+	}
+
+	users, err := NewRepository[User](db, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := users.List(ctx, ListOptions{OrderBy: []Order{Asc("id")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "Ann" || list[0].Age != 30 || list[1].Age != 0 || !list[1].Active {
+		t.Fatalf("users after migration = %+v", list)
+	}
+	if err := users.Create(ctx, &User{Name: "Cid", Email: "cid@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Откат и повторное применение — как на базе, созданной миграциями.
+	if _, err := migrator.To(ctx, 0); err != nil {
+		t.Fatalf("migrate to 0: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate up again: %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+-- IF NOT EXISTS: базы, созданные до миграций, уже содержат users со
+-- столбцами id, name, email и age. age там допускал NULL.
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	email TEXT UNIQUE NOT NULL,
+	age INTEGER NOT NULL DEFAULT 0
+);
+UPDATE users SET age = 0 WHERE age IS NULL;
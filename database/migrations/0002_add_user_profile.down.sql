@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN updated_at;
+ALTER TABLE users DROP COLUMN created_at;
+ALTER TABLE users DROP COLUMN active;
+ALTER TABLE users DROP COLUMN country;
@@ -0,0 +1,7 @@
+-- ALTER TABLE ADD COLUMN в SQLite принимает только постоянные значения по
+-- умолчанию, поэтому время существующих строк проставляется отдельно.
+ALTER TABLE users ADD COLUMN country TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+ALTER TABLE users ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+UPDATE users SET created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP;
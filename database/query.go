@@ -0,0 +1,206 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Cond — условие WHERE: текст с плейсхолдерами ? и их аргументы. Имена
+// столбцов подставляются в текст как есть, поэтому берутся из кода, а не из
+// запроса клиента.
+type Cond interface {
+	SQL() (string, []interface{})
+}
+
+type expr struct {
+	sql  string
+	args []interface{}
+}
+
+func (e expr) SQL() (string, []interface{}) {
+	return e.sql, e.args
+}
+
+// Expr — условие, записанное вручную: Expr("age BETWEEN ? AND ?", 18, 30).
+func Expr(sql string, args ...interface{}) Cond {
+	return expr{sql: sql, args: args}
+}
+
+// Eq — column = v; для nil — column IS NULL.
+func Eq(column string, v interface{}) Cond {
+	if v == nil {
+		return expr{sql: column + " IS NULL"}
+	}
+	return compare(column, "=", v)
+}
+
+// Ne — column <> v; для nil — column IS NOT NULL.
+func Ne(column string, v interface{}) Cond {
+	if v == nil {
+		return expr{sql: column + " IS NOT NULL"}
+	}
+	return compare(column, "<>", v)
+}
+
+func Gt(column string, v interface{}) Cond  { return compare(column, ">", v) }
+func Gte(column string, v interface{}) Cond { return compare(column, ">=", v) }
+func Lt(column string, v interface{}) Cond  { return compare(column, "<", v) }
+func Lte(column string, v interface{}) Cond { return compare(column, "<=", v) }
+
+// Like — column LIKE pattern; % и _ в pattern — шаблонные символы.
+func Like(column, pattern string) Cond { return compare(column, "LIKE", pattern) }
+
+func compare(column, op string, v interface{}) Cond {
+	return expr{sql: column + " " + op + " ?", args: []interface{}{v}}
+}
+
+// In — column IN (values...). Пустой список не совпадает ни с чем.
+func In(column string, values ...interface{}) Cond {
+	if len(values) == 0 {
+		return expr{sql: "1 = 0"}
+	}
+	return expr{sql: fmt.Sprintf("%s IN (%s)", column, placeholders(len(values))), args: values}
+}
+
+// And объединяет условия через AND; без условий — истина.
+func And(conds ...Cond) Cond { return join("AND", "1 = 1", conds) }
+
+// Or объединяет условия через OR; без условий — ложь.
+func Or(conds ...Cond) Cond { return join("OR", "1 = 0", conds) }
+
+func Not(c Cond) Cond {
+	sql, args := c.SQL()
+	return expr{sql: "NOT (" + sql + ")", args: args}
+}
+
+func join(op, empty string, conds []Cond) Cond {
+	if len(conds) == 0 {
+		return expr{sql: empty}
+	}
+	if len(conds) == 1 {
+		return conds[0]
+	}
+	parts := make([]string, len(conds))
+	var args []interface{}
+	for i, c := range conds {
+		sql, a := c.SQL()
+		parts[i] = "(" + sql + ")"
+		args = append(args, a...)
+	}
+	return expr{sql: strings.Join(parts, " "+op+" "), args: args}
+}
+
+// Order — сортировка по столбцу.
+type Order struct {
+	Column string
+	Desc   bool
+}
+
+func Asc(column string) Order  { return Order{Column: column} }
+func Desc(column string) Order { return Order{Column: column, Desc: true} }
+
+// Query собирает SELECT по таблице. Методы не меняют запрос, а возвращают
+// новый, так что общую часть можно собрать один раз и уточнять:
+//
+//	active := From("users").Where(Eq("active", true))
+//	q, args := active.Where(Gte("age", 18)).OrderBy(Desc("created_at")).Limit(10).SQL()
+type Query struct {
+	table   string
+	columns []string
+	where   []Cond
+	order   []Order
+	limit   int
+	offset  int
+}
+
+// From начинает запрос к table; без Select выбираются все столбцы.
+func From(table string) *Query {
+	return &Query{table: table}
+}
+
+func (q *Query) Select(columns ...string) *Query {
+	c := *q
+	c.columns = columns
+	return &c
+}
+
+// Where добавляет условия; все условия запроса объединяются через AND.
+func (q *Query) Where(conds ...Cond) *Query {
+	c := *q
+	c.where = append(q.where[:len(q.where):len(q.where)], conds...)
+	return &c
+}
+
+func (q *Query) OrderBy(orders ...Order) *Query {
+	c := *q
+	c.order = append(q.order[:len(q.order):len(q.order)], orders...)
+	return &c
+}
+
+// Limit ограничивает число строк; 0 — без ограничения.
+func (q *Query) Limit(n int) *Query {
+	c := *q
+	c.limit = n
+	return &c
+}
+
+func (q *Query) Offset(n int) *Query {
+	c := *q
+	c.offset = n
+	return &c
+}
+
+// SQL возвращает текст SELECT и аргументы.
+func (q *Query) SQL() (string, []interface{}) {
+	columns := "*"
+	if len(q.columns) > 0 {
+		columns = strings.Join(q.columns, ", ")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT %s FROM %s", columns, q.table)
+	args := q.writeWhere(&b)
+
+	if len(q.order) > 0 {
+		parts := make([]string, len(q.order))
+		for i, o := range q.order {
+			parts[i] = o.Column
+			if o.Desc {
+				parts[i] += " DESC"
+			}
+		}
+		b.WriteString(" ORDER BY " + strings.Join(parts, ", "))
+	}
+	switch {
+	case q.limit > 0:
+		fmt.Fprintf(&b, " LIMIT %d", q.limit)
+	case q.offset > 0:
+		// OFFSET в SQLite допустим только после LIMIT; -1 — без ограничения.
+		b.WriteString(" LIMIT -1")
+	}
+	if q.offset > 0 {
+		fmt.Fprintf(&b, " OFFSET %d", q.offset)
+	}
+	return b.String(), args
+}
+
+// CountSQL возвращает SELECT COUNT(*) с теми же условиями, без сортировки
+// и страниц — для общего числа строк при постраничном выводе.
+func (q *Query) CountSQL() (string, []interface{}) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT COUNT(*) FROM %s", q.table)
+	args := q.writeWhere(&b)
+	return b.String(), args
+}
+
+func (q *Query) writeWhere(b *strings.Builder) []interface{} {
+	if len(q.where) == 0 {
+		return nil
+	}
+	sql, args := And(q.where...).SQL()
+	b.WriteString(" WHERE " + sql)
+	return args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"go-showcase/reflection"
+)
+
+// ErrNotFound — строки с таким первичным ключом нет.
+var ErrNotFound = errors.New("database: record not found")
+
+// Querier — то, на чём выполняются запросы: *sql.DB, *sql.Tx или *sql.Conn.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// ListOptions — фильтры, сортировка и страница для Repository.List.
+type ListOptions struct {
+	Where   []Cond
+	OrderBy []Order
+	// Limit — 0 без ограничения.
+	Limit  int
+	Offset int
+}
+
+// Repository — CRUD для структуры T с тегами db (reflection.StructMap) в
+// таблице table. У T должен быть столбец первичного ключа (`db:"id,pk"`).
+type Repository[T any] struct {
+	db    Querier
+	table string
+	meta  *reflection.StructMap
+	pk    reflection.Column
+}
+
+func NewRepository[T any](db Querier, table string) (*Repository[T], error) {
+	meta, err := reflection.MapStruct(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	pk, ok := meta.PK()
+	if !ok {
+		return nil, fmt.Errorf("database: %s has no pk column", meta.Type)
+	}
+	return &Repository[T]{db: db, table: table, meta: meta, pk: pk}, nil
+}
+
+// With возвращает тот же репозиторий поверх db — например, транзакции.
+func (r *Repository[T]) With(db Querier) *Repository[T] {
+	c := *r
+	c.db = db
+	return &c
+}
+
+// Query — SELECT всех столбцов T; основа для Find.
+func (r *Repository[T]) Query() *Query {
+	return From(r.table).Select(r.meta.Names()...)
+}
+
+// Create вставляет entity. Если ключ был нулевым, в entity записывается
+// ключ, выданный базой; nil-указатель на встроенную структуру с ключом при
+// этом создаётся.
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	query, args, err := reflection.InsertSQL(r.table, entity)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(entity).Elem()
+	if key, err := v.FieldByIndexErr(r.pk.Index); err == nil && !key.IsZero() {
+		return nil
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	return r.meta.SetPK(v, id)
+}
+
+// Get возвращает строку с ключом id или ErrNotFound.
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (T, error) {
+	var entity T
+	query, args := r.Query().Where(Eq(r.pk.Name, id)).SQL()
+	err := reflection.ScanRow(r.db.QueryRowContext(ctx, query, args...), r.meta.Names(), &entity)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entity, ErrNotFound
+	}
+	return entity, err
+}
+
+// Update сохраняет все столбцы entity по её ключу; ErrNotFound, если такой
+// строки нет. Ключ внутри nil-указателя на встроенную структуру — ошибка,
+// а не ErrNotFound.
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	query, args, err := reflection.UpdateSQL(r.table, entity)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+// Delete удаляет строку с ключом id; ErrNotFound, если её нет. nil вместо
+// ключа — ошибка вызывающего, а не отсутствие строки.
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	if id == nil {
+		return fmt.Errorf("database: delete from %s with a nil %s", r.table, r.pk.Name)
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", r.table, r.pk.Name)
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+// List возвращает строки по фильтрам opts. Столбцы сортировки проверяются
+// по тегам T, так что их можно брать из параметров запроса.
+func (r *Repository[T]) List(ctx context.Context, opts ListOptions) ([]T, error) {
+	for _, o := range opts.OrderBy {
+		if _, ok := r.meta.Column(o.Column); !ok {
+			return nil, fmt.Errorf("database: cannot sort %s by unknown column %q", r.table, o.Column)
+		}
+	}
+	q := r.Query().Where(opts.Where...).OrderBy(opts.OrderBy...).Limit(opts.Limit).Offset(opts.Offset)
+	return r.Find(ctx, q)
+}
+
+// Find выполняет произвольный SELECT и читает строки в T по именам столбцов.
+func (r *Repository[T]) Find(ctx context.Context, q *Query) ([]T, error) {
+	query, args := q.SQL()
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities := []T{}
+	if err := reflection.ScanRows(rows, &entities); err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// Count считает строки, подходящие под условия.
+func (r *Repository[T]) Count(ctx context.Context, where ...Cond) (int, error) {
+	query, args := From(r.table).Where(where...).CountSQL()
+	var n int
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&n)
+	return n, err
+}
+
+func requireRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
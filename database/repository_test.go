@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type RepoKey struct {
+	ID int64 `db:"id,pk"`
+}
+
+// repoItem — ключ во встроенной структуре по указателю, как у сущностей,
+// которые делят общий набор служебных полей. RepoKey экспортируется, иначе
+// reflect не создаст указатель на неё при чтении строки.
+type repoItem struct {
+	*RepoKey
+	Name string `db:"name"`
+}
+
+func newTestRepository(t *testing.T) *Repository[repoItem] {
+	t.Helper()
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)`); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := NewRepository[repoItem](db, "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+// TestRepositoryNilEmbeddedKey: без ключа Update и Delete сообщают об
+// ошибке вызывающего, а не ErrNotFound.
+func TestRepositoryNilEmbeddedKey(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	if _, err := repo.db.ExecContext(ctx, `INSERT INTO items (id, name) VALUES (1, 'a')`); err != nil {
+		t.Fatal(err)
+	}
+
+	err := repo.Update(ctx, &repoItem{Name: "b"})
+	if err == nil || errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "nil embedded struct") {
+		t.Fatalf("Update without a key: err = %v, want nil embedded struct", err)
+	}
+	if err := repo.Delete(ctx, nil); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete(nil): err = %v, want a nil key error", err)
+	}
+
+	if err := repo.Update(ctx, &repoItem{RepoKey: &RepoKey{ID: 1}, Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.Get(ctx, 1)
+	if err != nil || got.Name != "b" {
+		t.Fatalf("Get(1) = %+v, %v; want name b", got, err)
+	}
+	if err := repo.Delete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second Delete(1): err = %v, want ErrNotFound", err)
+	}
+}
+
+// TestRepositoryCreateNilEmbeddedKey: ключ, выданный базой, записывается и
+// в сущность без встроенной структуры с ключом.
+func TestRepositoryCreateNilEmbeddedKey(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	for i, name := range []string{"a", "b"} {
+		item := &repoItem{Name: name}
+		if err := repo.Create(ctx, item); err != nil {
+			t.Fatalf("Create(%s): %v", name, err)
+		}
+		if item.RepoKey == nil || item.ID != int64(i+1) {
+			t.Fatalf("Create(%s): key = %+v, want %d", name, item.RepoKey, i+1)
+		}
+	}
+
+	// Явный ключ сохраняется как есть.
+	explicit := &repoItem{RepoKey: &RepoKey{ID: 10}, Name: "c"}
+	if err := repo.Create(ctx, explicit); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.Get(ctx, 10)
+	if err != nil || got.Name != "c" || got.ID != 10 {
+		t.Fatalf("Get(10) = %+v, %v; want c", got, err)
+	}
+}
@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// TxOptions — настройки транзакции WithTx.
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// MaxRetries — сколько раз транзакция перезапускается, если SQLite
+	// ответила SQLITE_BUSY или SQLITE_LOCKED: база занята другим
+	// соединением или процессом.
+	MaxRetries int
+	// Backoff — пауза перед первым перезапуском; дальше она удваивается
+	// (не больше секунды) и слегка рандомизируется.
+	Backoff time.Duration
+}
+
+// DefaultTxOptions используются, когда WithTx получает nil.
+var DefaultTxOptions = TxOptions{MaxRetries: 5, Backoff: 10 * time.Millisecond}
+
+const maxTxBackoff = time.Second
+
+// PanicError — паника в функции транзакции. Транзакция при этом откатывается,
+// а паника возвращается как ошибка со стеком.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("database: panic in transaction: %v", e.Value)
+}
+
+// Unwrap возвращает значение паники, если это ошибка.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+type txKey struct{}
+
+// txState — открытая WithTx транзакция; передаётся вложенным вызовам через
+// контекст.
+type txState struct {
+	db         *sql.DB
+	tx         *sql.Tx
+	savepoints int
+}
+
+// WithTx выполняет fn в транзакции на db: если fn вернула nil, транзакция
+// фиксируется, если ошибку или запаниковала — откатывается. Запросы fn
+// должна выполнять через tx (например, Repository.With(tx)) и с переданным
+// ей ctx:
+//
+//	err := WithTx(ctx, db, nil, func(ctx context.Context, tx Querier) error {
+//		return users.With(tx).Create(ctx, &user)
+//	})
+//
+// Вызов WithTx внутри fn с этим ctx и тем же db не начинает новую
+// транзакцию, а ставит точку сохранения (SAVEPOINT): ошибка вложенной fn
+// откатывает только её изменения, а внешняя решает, продолжать ли. Вложенные
+// вызовы идут последовательно — транзакция занимает одно соединение.
+//
+// Если база занята (SQLITE_BUSY/SQLITE_LOCKED), транзакция целиком
+// перезапускается по opts, так что fn может выполниться несколько раз и не
+// должна иметь побочных эффектов вне базы. Для вложенных вызовов opts не
+// используются: перезапускает только внешняя транзакция.
+func WithTx(ctx context.Context, db *sql.DB, opts *TxOptions, fn func(ctx context.Context, tx Querier) error) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok && state.db == db {
+		return state.savepoint(ctx, fn)
+	}
+	if opts == nil {
+		opts = &DefaultTxOptions
+	}
+
+	delay := opts.Backoff
+	for attempt := 0; ; attempt++ {
+		err := runTx(ctx, db, opts, fn)
+		if err == nil || !isBusy(err) || attempt >= opts.MaxRetries {
+			return err
+		}
+		timer := time.NewTimer(jitter(delay))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay = min(delay*2, maxTxBackoff)
+	}
+}
+
+func runTx(ctx context.Context, db *sql.DB, opts *TxOptions, fn func(context.Context, Querier) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return err
+	}
+	state := &txState{db: db, tx: tx}
+	if err := call(context.WithValue(ctx, txKey{}, state), tx, fn); err != nil {
+		rbErr := tx.Rollback()
+		if errors.Is(rbErr, sql.ErrTxDone) {
+			// Отменённый ctx уже откатил транзакцию.
+			rbErr = nil
+		}
+		return rollbackErr(err, rbErr)
+	}
+	return tx.Commit()
+}
+
+// savepoint выполняет вложенную fn внутри открытой транзакции.
+func (s *txState) savepoint(ctx context.Context, fn func(context.Context, Querier) error) error {
+	s.savepoints++
+	name := fmt.Sprintf("sp_%d", s.savepoints)
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+	if err := call(ctx, s.tx, fn); err != nil {
+		// ROLLBACK TO оставляет точку сохранения открытой, RELEASE её снимает.
+		_, rbErr := s.tx.ExecContext(ctx, "ROLLBACK TO "+name)
+		if rbErr == nil {
+			_, rbErr = s.tx.ExecContext(ctx, "RELEASE "+name)
+		}
+		return rollbackErr(err, rbErr)
+	}
+	_, err := s.tx.ExecContext(ctx, "RELEASE "+name)
+	return err
+}
+
+// call вызывает fn, превращая панику в *PanicError.
+func call(ctx context.Context, tx Querier, fn func(context.Context, Querier) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = &PanicError{Value: p, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx, tx)
+}
+
+func rollbackErr(err, rbErr error) error {
+	if rbErr == nil {
+		return err
+	}
+	return errors.Join(err, fmt.Errorf("database: rollback: %w", rbErr))
+}
+
+// isBusy — ошибка из-за блокировки базы, после которой транзакцию можно
+// повторить.
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// jitter возвращает случайную паузу от d/2 до d, чтобы соперничающие
+// транзакции не перезапускались одновременно.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
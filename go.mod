@@ -4,9 +4,7 @@ go 1.21
 
 require (
 	github.com/gorilla/mux v1.8.1
-	github.com/gorilla/websocket v1.5.1
+	github.com/gorilla/websocket v1.5.3
 	github.com/mattn/go-sqlite3 v1.14.32
 	golang.org/x/time v0.5.0
 )
-
-require golang.org/x/net v0.17.0 // indirect
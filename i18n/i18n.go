@@ -0,0 +1,226 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage — язык ответа, если клиент не выбрал поддерживаемый.
+const DefaultLanguage = "en"
+
+// LangParam — параметр запроса, который важнее Accept-Language.
+const LangParam = "lang"
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// Default — каталог из locales/*.json, встроенных в бинарник.
+var Default = mustLoad(locales, "locales")
+
+// Message — сообщение бандла: строка или формы множественного числа
+// ({"one": ..., "few": ..., "many": ..., "other": ...}). Текст — формат
+// для fmt.Sprintf.
+type Message map[Form]string
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*m = Message{Other: s}
+		return nil
+	}
+	var forms map[Form]string
+	if err := json.Unmarshal(data, &forms); err != nil {
+		return err
+	}
+	if forms[Other] == "" {
+		return fmt.Errorf("plural message without %q form", Other)
+	}
+	*m = forms
+	return nil
+}
+
+// Locale — правила форматирования чисел и дат языка.
+type Locale struct {
+	Decimal  string `json:"decimal"`
+	Group    string `json:"group"`
+	Date     string `json:"date"`
+	DateTime string `json:"datetime"`
+	// ListSeparator — разделитель полей CSV: там, где запятая — десятичный
+	// разделитель, табличные редакторы ждут точку с запятой.
+	ListSeparator string `json:"list_separator"`
+}
+
+// Bundle — сообщения одного языка.
+type Bundle struct {
+	Lang     string
+	Locale   Locale
+	Messages map[string]Message
+}
+
+// Catalog — бандлы всех поддерживаемых языков.
+type Catalog struct {
+	bundles  map[string]*Bundle
+	fallback string
+}
+
+// Load читает бандлы <lang>.json из dir. Файл — объект с сообщениями по
+// ключам и правилами форматирования в "_locale".
+func Load(fsys fs.FS, dir string) (*Catalog, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	c := &Catalog{bundles: make(map[string]*Bundle), fallback: DefaultLanguage}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		lang := strings.TrimSuffix(path.Base(file), ".json")
+		b := &Bundle{Lang: lang, Messages: make(map[string]Message, len(raw))}
+		for key, value := range raw {
+			if key == "_locale" {
+				err = json.Unmarshal(value, &b.Locale)
+			} else {
+				var m Message
+				err = json.Unmarshal(value, &m)
+				b.Messages[key] = m
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", file, key, err)
+			}
+		}
+		c.bundles[lang] = b
+	}
+	if c.bundles[c.fallback] == nil {
+		return nil, fmt.Errorf("no bundle for default language %q", c.fallback)
+	}
+	return c, nil
+}
+
+func mustLoad(fsys fs.FS, dir string) *Catalog {
+	c, err := Load(fsys, dir)
+	if err != nil {
+		panic("i18n: " + err.Error())
+	}
+	return c
+}
+
+// Languages возвращает поддерживаемые языки по алфавиту.
+func (c *Catalog) Languages() []string {
+	langs := make([]string, 0, len(c.bundles))
+	for lang := range c.bundles {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+func (c *Catalog) Supports(lang string) bool {
+	_, ok := c.bundles[lang]
+	return ok
+}
+
+// Printer возвращает переводчик для lang; неподдерживаемый язык заменяется
+// языком по умолчанию.
+func (c *Catalog) Printer(lang string) *Printer {
+	b, ok := c.bundles[lang]
+	if !ok {
+		b = c.bundles[c.fallback]
+	}
+	return &Printer{Lang: b.Lang, bundle: b, fallback: c.bundles[c.fallback]}
+}
+
+// Negotiate выбирает язык запроса: ?lang=, затем Accept-Language с учётом
+// весов q, затем язык по умолчанию.
+func (c *Catalog) Negotiate(r *http.Request) string {
+	if lang := baseLanguage(r.URL.Query().Get(LangParam)); c.Supports(lang) {
+		return lang
+	}
+	best, bestQ := c.fallback, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if lang := baseLanguage(tag); q > bestQ && c.Supports(lang) {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// baseLanguage отбрасывает регион: "ru-RU" — "ru".
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	return base
+}
+
+// Negotiate выбирает язык запроса по каталогу Default.
+func Negotiate(r *http.Request) string {
+	return Default.Negotiate(r)
+}
+
+// For возвращает переводчик на язык запроса: из контекста, если его
+// положил Middleware, иначе — по заголовкам и ?lang=.
+func For(r *http.Request) *Printer {
+	if lang, ok := LanguageFromContext(r.Context()); ok {
+		return Default.Printer(lang)
+	}
+	return Default.Printer(Negotiate(r))
+}
+
+// Lang возвращает переводчик каталога Default.
+func Lang(lang string) *Printer {
+	return Default.Printer(lang)
+}
+
+type languageKey struct{}
+
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+func LanguageFromContext(ctx context.Context) (string, bool) {
+	lang, ok := ctx.Value(languageKey{}).(string)
+	return lang, ok
+}
+
+// Middleware выбирает язык один раз на запрос и кладёт его в контекст.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := Negotiate(r)
+		VaryLanguage(w.Header())
+		next.ServeHTTP(w, r.WithContext(WithLanguage(r.Context(), lang)))
+	})
+}
+
+// VaryLanguage добавляет Accept-Language в Vary, если его там ещё нет:
+// кэши должны различать ответы на разных языках.
+func VaryLanguage(h http.Header) {
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(name), "Accept-Language") {
+				return
+			}
+		}
+	}
+	h.Add("Vary", "Accept-Language")
+}
@@ -0,0 +1,36 @@
+package i18n
+
+// Form — категория множественного числа по CLDR.
+type Form string
+
+const (
+	One   Form = "one"
+	Few   Form = "few"
+	Many  Form = "many"
+	Other Form = "other"
+)
+
+// PluralForm выбирает форму для целого n по правилам CLDR. Для языков без
+// правил используется английское: one для 1, other для остальных.
+func PluralForm(lang string, n int) Form {
+	if n < 0 {
+		n = -n
+	}
+	switch lang {
+	case "ru", "uk", "be":
+		mod10, mod100 := n%10, n%100
+		switch {
+		case mod10 == 1 && mod100 != 11:
+			return One
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return Few
+		default:
+			return Many
+		}
+	default:
+		if n == 1 {
+			return One
+		}
+		return Other
+	}
+}
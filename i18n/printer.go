@@ -0,0 +1,149 @@
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Printer переводит сообщения и форматирует числа и даты на одном языке.
+type Printer struct {
+	Lang string
+
+	bundle   *Bundle
+	fallback *Bundle
+}
+
+// T возвращает сообщение key с подставленными args. Если в бандле языка
+// ключа нет, берётся язык по умолчанию, если нет и там — сам ключ.
+func (p *Printer) T(key string, args ...interface{}) string {
+	return p.format(key, Other, args)
+}
+
+// N выбирает форму множественного числа по n. n в текст не подставляется
+// сам: передайте его и в args, если он нужен в сообщении.
+func (p *Printer) N(key string, n int, args ...interface{}) string {
+	return p.format(key, PluralForm(p.Lang, n), args)
+}
+
+// Has сообщает, есть ли перевод ключа на языке принтера.
+func (p *Printer) Has(key string) bool {
+	_, ok := p.bundle.Messages[key]
+	return ok
+}
+
+// Messages возвращает все сообщения с префиксом prefix без префикса в
+// ключах — для передачи в JavaScript.
+func (p *Printer) Messages(prefix string) map[string]string {
+	out := make(map[string]string)
+	for _, b := range []*Bundle{p.fallback, p.bundle} {
+		for key, m := range b.Messages {
+			if strings.HasPrefix(key, prefix) {
+				out[strings.TrimPrefix(key, prefix)] = m[Other]
+			}
+		}
+	}
+	return out
+}
+
+func (p *Printer) format(key string, form Form, args []interface{}) string {
+	m, ok := p.bundle.Messages[key]
+	if !ok {
+		m, ok = p.fallback.Messages[key]
+	}
+	if !ok {
+		return key
+	}
+	text, ok := m[form]
+	if !ok {
+		text = m[Other]
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// Int форматирует целое с разделителем разрядов: 12,345 или 12 345.
+func (p *Printer) Int(n int) string {
+	s := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	return sign + group(s, p.locale().Group)
+}
+
+// Number форматирует число с decimals знаками после запятой.
+func (p *Printer) Number(v float64, decimals int) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	whole, frac, _ := strings.Cut(s, ".")
+	out := group(whole, p.locale().Group)
+	if frac != "" {
+		out += p.locale().Decimal + frac
+	}
+	if v < 0 && strings.Trim(s, "0.") != "" {
+		out = "-" + out
+	}
+	return out
+}
+
+func (p *Printer) Date(t time.Time) string {
+	return t.Format(p.locale().Date)
+}
+
+func (p *Printer) DateTime(t time.Time) string {
+	return t.Format(p.locale().DateTime)
+}
+
+// ListSeparator — разделитель полей CSV для языка.
+func (p *Printer) ListSeparator() rune {
+	for _, r := range p.locale().ListSeparator {
+		return r
+	}
+	return ','
+}
+
+// locale дополняет правила языка правилами языка по умолчанию.
+func (p *Printer) locale() Locale {
+	l, def := p.bundle.Locale, p.fallback.Locale
+	if l.Decimal == "" {
+		l.Decimal = def.Decimal
+	}
+	if l.Group == "" {
+		l.Group = def.Group
+	}
+	if l.Date == "" {
+		l.Date = def.Date
+	}
+	if l.DateTime == "" {
+		l.DateTime = def.DateTime
+	}
+	if l.ListSeparator == "" {
+		l.ListSeparator = def.ListSeparator
+	}
+	return l
+}
+
+func group(digits, sep string) string {
+	if len(digits) <= 3 || sep == "" {
+		return digits
+	}
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
@@ -152,6 +152,10 @@ type ValidationError struct {
 	Field string
 	Value interface{}
 	Msg   string
+	// Rule и Param — нарушенное правило тега validate и его параметр
+	// (например "max" и "150"), если ошибку вернул reflection.Validate.
+	Rule  string
+	Param string
 }
 
 func (e *ValidationError) Error() string {
@@ -17,8 +17,13 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	fmt.Println("=== GO Language Showcase ===")
-	fmt.Println("Демонстрация всех возможностей Go\n")
+	fmt.Println("Демонстрация всех возможностей Go")
+	fmt.Println()
 
 	fmt.Println("--- 1. Типы данных ---")
 	types.DemoBasicTypes()
@@ -75,7 +80,8 @@ func main() {
 	fmt.Println("  ✅ Graceful Shutdown")
 	fmt.Println("  ✅ Structured Logging")
 	fmt.Println("\nОткройте браузер: http://localhost:8080")
-	fmt.Println("Нажмите Ctrl+C для graceful остановки сервера\n")
+	fmt.Println("Нажмите Ctrl+C для graceful остановки сервера")
+	fmt.Println()
 	
 	server.StartServer()
 }
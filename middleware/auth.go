@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-showcase/problem"
+)
+
+// Principal — аутентифицированный клиент API или WebSocket.
+type Principal struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Tier string `json:"tier,omitempty"`
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+type Authenticator interface {
+	Authenticate(token string) (*Principal, bool)
+}
+
+// Auth проверяет токен из "Authorization: Bearer <token>" или X-API-Key и
+// кладёт Principal в контекст запроса. Запросы без токена проходят как
+// анонимные, с неверным токеном — получают 401.
+func Auth(a Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get("X-API-Key")
+			if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				token = strings.TrimPrefix(auth, "Bearer ")
+			}
+			if token == "" || a == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, ok := a.Authenticate(token)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				problem.Write(w, r, problem.New(problem.InvalidToken))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// StaticTokens — простейший Authenticator: фиксированная таблица токенов.
+type StaticTokens map[string]Principal
+
+func (t StaticTokens) Authenticate(token string) (*Principal, bool) {
+	p, ok := t[token]
+	if !ok {
+		return nil, false
+	}
+	return &p, true
+}
+
+// ParseTokens разбирает строку вида "token1:alice,token2:bob:pro",
+// где после токена идут ID пользователя и (необязательно) его тариф.
+func ParseTokens(spec string) (StaticTokens, error) {
+	tokens := StaticTokens{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid token entry %q: want token:user[:tier]", entry)
+		}
+		p := Principal{ID: parts[1], Name: parts[1]}
+		if len(parts) == 3 {
+			p.Tier = parts[2]
+		}
+		tokens[parts[0]] = p
+	}
+	return tokens, nil
+}
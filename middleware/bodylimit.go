@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"go-showcase/problem"
+)
+
+// BodyLimitConfig ограничивает размер тела запроса.
+type BodyLimitConfig struct {
+	// Default — лимит в байтах; 0 — без лимита.
+	Default int64
+	// Routes переопределяет Default для шаблона маршрута или пары
+	// "МЕТОД шаблон".
+	Routes map[string]int64
+}
+
+func (c BodyLimitConfig) limitFor(r *http.Request) int64 {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return c.Default
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return c.Default
+	}
+	if limit, ok := c.Routes[r.Method+" "+template]; ok {
+		return limit
+	}
+	if limit, ok := c.Routes[template]; ok {
+		return limit
+	}
+	return c.Default
+}
+
+// BodyLimit отвечает 413, если Content-Length больше лимита маршрута, а
+// тело без Content-Length оборачивает в http.MaxBytesReader: чтение сверх
+// лимита вернёт *http.MaxBytesError.
+func BodyLimit(cfg BodyLimitConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := cfg.limitFor(r)
+			if limit <= 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.ContentLength > limit {
+				problem.Write(w, r, problem.New(problem.PayloadTooLarge, limit))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CachePolicy включает кэширование ответов маршрута.
+type CachePolicy struct {
+	// TTL — сколько ответ хранится на сервере; 0 — CacheConfig.TTL.
+	TTL time.Duration
+	// MaxAge — max-age для клиента; 0 — клиент обязан перепроверять
+	// ответ (no-cache) условным запросом.
+	MaxAge time.Duration
+	// Tags — от каких данных зависит ответ: Invalidate(tag) делает
+	// устаревшими все ответы с этим тегом.
+	Tags []string
+}
+
+type CacheConfig struct {
+	// MaxEntries — размер LRU; при переполнении вытесняются давно
+	// не запрошенные ответы.
+	MaxEntries int
+	// MaxBodySize — ответы больше этого размера не кэшируются.
+	MaxBodySize int
+	TTL         time.Duration
+	// Vary — заголовки запроса, от которых зависит ответ; входят в ключ
+	// кэша и в заголовок Vary ответа.
+	Vary []string
+	// Routes — политики по шаблонам маршрутов ("/api/users/{id}" или
+	// "GET /api/users"); остальные маршруты не кэшируются.
+	Routes map[string]CachePolicy
+}
+
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		MaxEntries:  1000,
+		MaxBodySize: 1 << 20,
+		TTL:         time.Minute,
+		Vary:        []string{"Accept", "Accept-Language"},
+	}
+}
+
+func (c CacheConfig) normalize() CacheConfig {
+	def := DefaultCacheConfig()
+	if c.MaxEntries <= 0 {
+		c.MaxEntries = def.MaxEntries
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = def.MaxBodySize
+	}
+	if c.TTL <= 0 {
+		c.TTL = def.TTL
+	}
+	return c
+}
+
+type cacheEntry struct {
+	key          string
+	header       http.Header
+	body         []byte
+	etag         string
+	lastModified time.Time
+	expires      time.Time
+	// generations — поколения тегов на момент, когда обработчик начал
+	// читать данные; если с тех пор тег инвалидирован, ответ устарел.
+	generations map[string]uint64
+}
+
+// ResponseCache — LRU-кэш ответов GET с ETag, Last-Modified и ответами 304.
+// Устаревание по записи устроено через поколения тегов: Invalidate лишь
+// увеличивает счётчик, а ответ, который начал вычисляться до записи,
+// сохранится уже устаревшим и не будет отдан.
+type ResponseCache struct {
+	cfg CacheConfig
+
+	mu          sync.Mutex
+	lru         *list.List
+	entries     map[string]*list.Element
+	generations map[string]uint64
+	// modified — по тегу: не раньше какого момента должен быть
+	// Last-Modified нового ответа с этим тегом.
+	modified map[string]time.Time
+
+	hits          uint64
+	misses        uint64
+	notModified   uint64
+	evictions     uint64
+	invalidations uint64
+}
+
+func NewResponseCache(cfg CacheConfig) *ResponseCache {
+	return &ResponseCache{
+		cfg:         cfg.normalize(),
+		lru:         list.New(),
+		entries:     make(map[string]*list.Element),
+		generations: make(map[string]uint64),
+		modified:    make(map[string]time.Time),
+	}
+}
+
+// Invalidate делает устаревшими все ответы с указанными тегами.
+func (c *ResponseCache) Invalidate(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, tag := range tags {
+		c.generations[tag]++
+		// Last-Modified с точностью до секунды: ответ, закэшированный после
+		// записи в ту же секунду, должен получить время строго позже
+		// выданного до неё, иначе If-Modified-Since вернёт 304 на старые
+		// данные.
+		c.modified[tag] = later(now, c.modified[tag].Add(time.Second))
+	}
+	c.invalidations++
+}
+
+// policyFor, как и у RateLimiter, работает только внутри mux.Router.
+func (c *ResponseCache) policyFor(r *http.Request) (CachePolicy, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return CachePolicy{}, false
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return CachePolicy{}, false
+	}
+	if policy, ok := c.cfg.Routes[r.Method+" "+template]; ok {
+		return policy, true
+	}
+	policy, ok := c.cfg.Routes[template]
+	return policy, ok
+}
+
+func (c *ResponseCache) key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte(' ')
+	b.WriteString(r.URL.Path)
+	b.WriteByte('?')
+	// Encode сортирует параметры: ?a=1&b=2 и ?b=2&a=1 — один ключ.
+	b.WriteString(r.URL.Query().Encode())
+	for _, name := range c.cfg.Vary {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+func (c *ResponseCache) get(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) || c.stale(entry) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		c.misses++
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	c.hits++
+	return entry, true
+}
+
+func (c *ResponseCache) stale(entry *cacheEntry) bool {
+	for tag, gen := range entry.generations {
+		if c.generations[tag] != gen {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot запоминает поколения тегов и выбирает Last-Modified ответа:
+// не раньше текущей секунды и отметок modified его тегов.
+func (c *ResponseCache) snapshot(tags []string) (map[string]uint64, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	generations := make(map[string]uint64, len(tags))
+	lastModified := time.Now().UTC().Truncate(time.Second)
+	for _, tag := range tags {
+		generations[tag] = c.generations[tag]
+		lastModified = later(lastModified, c.modified[tag])
+	}
+	for _, tag := range tags {
+		c.modified[tag] = lastModified
+	}
+	return generations, lastModified
+}
+
+func later(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+func (c *ResponseCache) put(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stale(entry) {
+		return
+	}
+	if el, ok := c.entries[entry.key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.cfg.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.evictions++
+	}
+}
+
+// Middleware отдаёт ответы маршрутов с CachePolicy из кэша. Ответы 200
+// получают ETag, Last-Modified и Cache-Control; на If-None-Match и
+// If-Modified-Since отвечает 304. Кэшируются только заголовки, которые
+// выставил обработчик: X-Request-ID, RateLimit-* и прочие заголовки
+// внешних middleware у каждого ответа свои. Подключается через router.Use
+// после RateLimiter, чтобы попадания в кэш тоже расходовали лимит.
+func (c *ResponseCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		policy, ok := c.policyFor(r)
+		requestCC := strings.ToLower(r.Header.Get("Cache-Control"))
+		if !ok || strings.Contains(requestCC, "no-store") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := c.key(r)
+		// no-cache от клиента — взять свежий ответ, минуя кэш.
+		if !strings.Contains(requestCC, "no-cache") {
+			if entry, ok := c.get(key); ok {
+				c.serve(w, r, policy, entry, "HIT")
+				return
+			}
+		}
+
+		generations, lastModified := c.snapshot(policy.Tags)
+		rec := &cacheRecorder{header: make(http.Header)}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status != http.StatusOK || rec.header.Get("Set-Cookie") != "" ||
+			strings.Contains(rec.header.Get("Cache-Control"), "no-store") {
+			copyHeader(w.Header(), rec.header)
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+			return
+		}
+
+		ttl := policy.TTL
+		if ttl <= 0 {
+			ttl = c.cfg.TTL
+		}
+		sum := sha256.Sum256(rec.body.Bytes())
+		now := time.Now()
+		entry := &cacheEntry{
+			key:          key,
+			header:       rec.header,
+			body:         rec.body.Bytes(),
+			etag:         `"` + base64.RawURLEncoding.EncodeToString(sum[:12]) + `"`,
+			lastModified: lastModified,
+			expires:      now.Add(ttl),
+			generations:  generations,
+		}
+		if len(entry.body) <= c.cfg.MaxBodySize {
+			c.put(entry)
+		}
+		c.serve(w, r, policy, entry, "MISS")
+	})
+}
+
+func (c *ResponseCache) serve(w http.ResponseWriter, r *http.Request, policy CachePolicy, entry *cacheEntry, status string) {
+	h := w.Header()
+	copyHeader(h, entry.header)
+	h.Set("ETag", entry.etag)
+	h.Set("Last-Modified", entry.lastModified.Format(http.TimeFormat))
+	if policy.MaxAge > 0 {
+		h.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(policy.MaxAge.Seconds())))
+	} else {
+		h.Set("Cache-Control", "private, no-cache")
+	}
+	for _, name := range c.cfg.Vary {
+		addVary(h, name)
+	}
+	h.Set("X-Cache", status)
+
+	if notModified(r, entry) {
+		c.mu.Lock()
+		c.notModified++
+		c.mu.Unlock()
+		// В ответе 304 не должно быть заголовков тела.
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(entry.body)
+}
+
+// notModified проверяет условия запроса по RFC 9110: If-None-Match, если
+// он есть, важнее If-Modified-Since.
+func notModified(r *http.Request, entry *cacheEntry) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == entry.etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !entry.lastModified.After(t)
+	}
+	return false
+}
+
+// addVary добавляет name в Vary, если его там ещё нет: Accept-Language,
+// например, выставляет уже i18n.Middleware.
+func addVary(h http.Header, name string) {
+	for _, v := range h.Values("Vary") {
+		for _, existing := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = append([]string(nil), v...)
+	}
+}
+
+func (c *ResponseCache) Stats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]interface{}{
+		"entries":       c.lru.Len(),
+		"max_entries":   c.cfg.MaxEntries,
+		"hits":          c.hits,
+		"misses":        c.misses,
+		"not_modified":  c.notModified,
+		"evictions":     c.evictions,
+		"invalidations": c.invalidations,
+	}
+}
+
+// cacheRecorder копит ответ обработчика, пока не ясно, попадёт ли он в кэш.
+type cacheRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *cacheRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *cacheRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *cacheRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
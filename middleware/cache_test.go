@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestCacheLastModifiedAfterInvalidate: кэш, запись и повторный кэш в одну
+// секунду — If-Modified-Since со старым Last-Modified не получает 304.
+func TestCacheLastModifiedAfterInvalidate(t *testing.T) {
+	cache := NewResponseCache(CacheConfig{
+		Routes: map[string]CachePolicy{"/api/users": {Tags: []string{"users"}}},
+	})
+	version := "v1"
+	router := mux.NewRouter()
+	router.Use(cache.Middleware)
+	router.HandleFunc("/api/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(version))
+	})
+
+	get := func(ifModifiedSince string) *countingWriter {
+		r := quietRequest(http.MethodGet, "/api/users")
+		if ifModifiedSince != "" {
+			r.Header.Set("If-Modified-Since", ifModifiedSince)
+		}
+		w := newCountingWriter()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	first := get("")
+	stale := first.Header().Get("Last-Modified")
+	for i := 2; i <= 3; i++ {
+		version = fmt.Sprintf("v%d", i)
+		cache.Invalidate("users")
+
+		w := get(stale)
+		if w.Code != http.StatusOK || w.Body.String() != version {
+			t.Fatalf("after write %d: got %d %q, want 200 %q", i, w.Code, w.Body.String(), version)
+		}
+		fresh := w.Header().Get("Last-Modified")
+		prev, _ := http.ParseTime(stale)
+		next, _ := http.ParseTime(fresh)
+		if !next.After(prev) {
+			t.Fatalf("after write %d: Last-Modified %s is not later than %s", i, fresh, stale)
+		}
+		if w := get(fresh); w.Code != http.StatusNotModified {
+			t.Fatalf("after write %d: conditional request got %d, want 304", i, w.Code)
+		}
+		stale = fresh
+	}
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIPResolver определяет адрес клиента за доверенными прокси.
+// Заголовкам X-Forwarded-For и Forwarded верим, только если запрос пришёл
+// от доверенного прокси: цепочка разбирается справа налево, и первый
+// недоверенный адрес считается адресом клиента.
+type ClientIPResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewClientIPResolver принимает адреса и подсети ("10.0.0.0/8", "127.0.0.1").
+func NewClientIPResolver(trusted []string) (*ClientIPResolver, error) {
+	resolver := &ClientIPResolver{}
+	for _, entry := range trusted {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			resolver.trusted = append(resolver.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", entry, err)
+		}
+		resolver.trusted = append(resolver.trusted, network)
+	}
+	return resolver, nil
+}
+
+func (c *ClientIPResolver) isTrusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range c.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP возвращает адрес клиента без порта. Nil-резолвер и резолвер без
+// доверенных прокси всегда возвращают адрес TCP-соединения.
+func (c *ClientIPResolver) ClientIP(r *http.Request) string {
+	remote := RemoteIP(r)
+	if c == nil || len(c.trusted) == 0 || !c.isTrusted(remote) {
+		return remote
+	}
+
+	hops := forwardedFor(r)
+	if hops == nil {
+		hops = xForwardedFor(r)
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := hops[i]
+		if net.ParseIP(hop) == nil {
+			// "unknown", обфусцированный идентификатор или мусор: дальше
+			// цепочке верить нельзя, клиентом считаем последний разобранный узел.
+			break
+		}
+		client = hop
+		if !c.isTrusted(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// FromTrustedProxy сообщает, пришёл ли запрос от доверенного прокси, то
+// есть можно ли верить его X-Forwarded-* заголовкам.
+func (c *ClientIPResolver) FromTrustedProxy(r *http.Request) bool {
+	return c != nil && c.isTrusted(RemoteIP(r))
+}
+
+// RemoteIP — адрес TCP-соединения без порта.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func xForwardedFor(r *http.Request) []string {
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, stripPort(strings.TrimSpace(hop)))
+		}
+	}
+	return hops
+}
+
+// forwardedFor достаёт параметры for= из заголовков Forwarded (RFC 7239).
+// Возвращает nil, если заголовка нет.
+func forwardedFor(r *http.Request) []string {
+	headers := r.Header.Values("Forwarded")
+	if len(headers) == 0 {
+		return nil
+	}
+
+	hops := []string{}
+	for _, header := range headers {
+		for _, element := range strings.Split(header, ",") {
+			hop := "unknown"
+			for _, pair := range strings.Split(element, ";") {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					hop = stripPort(strings.Trim(value, `"`))
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// stripPort убирает порт из "1.2.3.4:80" и "[2001:db8::1]:80"; голый IPv6
+// адрес возвращается как есть.
+func stripPort(hop string) string {
+	if strings.HasPrefix(hop, "[") {
+		if end := strings.Index(hop, "]"); end > 0 {
+			return hop[1:end]
+		}
+		return hop
+	}
+	if strings.Count(hop, ":") == 1 {
+		host, _, _ := strings.Cut(hop, ":")
+		return host
+	}
+	return hop
+}
@@ -0,0 +1,307 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-showcase/problem"
+)
+
+// CORSConfig описывает политику CORS. Разрешённые источники задаются:
+//   - точно: "https://app.example.com";
+//   - поддоменом: "https://*.example.com" (любая глубина, но не сам example.com);
+//   - регулярным выражением: "regex:^https://pr-[0-9]+\.example\.com$";
+//   - "*" — любой источник.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+
+	// Routes переопределяет политику для шаблона маршрута mux или пары
+	// "МЕТОД шаблон". Незаданные поля берутся из основной политики,
+	// AllowCredentials переопределение может только включить;
+	// Routes внутри переопределения не учитываются.
+	Routes map[string]CORSConfig
+}
+
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key", "Idempotency-Key"},
+		ExposedHeaders: []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "X-Response-Time", "X-Request-ID", "Idempotent-Replayed"},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
+type corsPolicy struct {
+	anyOrigin   bool
+	exact       map[string]bool
+	suffixes    []originSuffix
+	patterns    []*regexp.Regexp
+	methods     map[string]bool
+	headers     map[string]bool
+	anyHeader   bool
+	credentials bool
+
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+	maxAge        string
+}
+
+type originSuffix struct {
+	scheme string
+	suffix string
+}
+
+func compileCORS(cfg CORSConfig) (*corsPolicy, error) {
+	p := &corsPolicy{
+		exact:       make(map[string]bool),
+		methods:     make(map[string]bool),
+		headers:     make(map[string]bool),
+		credentials: cfg.AllowCredentials,
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.TrimSpace(origin)
+		if !strings.HasPrefix(origin, "regex:") {
+			origin = strings.TrimSuffix(origin, "/")
+		}
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.HasPrefix(origin, "regex:"):
+			re, err := regexp.Compile(strings.TrimPrefix(origin, "regex:"))
+			if err != nil {
+				return nil, fmt.Errorf("cors origin %q: %v", origin, err)
+			}
+			p.patterns = append(p.patterns, re)
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "://*.")
+			p.suffixes = append(p.suffixes, originSuffix{
+				scheme: strings.ToLower(scheme) + "://",
+				suffix: "." + strings.ToLower(host),
+			})
+		case origin != "":
+			p.exact[strings.ToLower(origin)] = true
+		}
+	}
+	methods := make([]string, 0, len(cfg.AllowedMethods))
+	for _, method := range cfg.AllowedMethods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		p.methods[method] = true
+		methods = append(methods, method)
+	}
+	p.allowMethods = strings.Join(methods, ", ")
+
+	for _, header := range cfg.AllowedHeaders {
+		header = strings.TrimSpace(header)
+		if header == "*" {
+			p.anyHeader = true
+		}
+		p.headers[http.CanonicalHeaderKey(header)] = true
+	}
+	p.allowHeaders = strings.Join(cfg.AllowedHeaders, ", ")
+	p.exposeHeaders = strings.Join(cfg.ExposedHeaders, ", ")
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	return p, nil
+}
+
+// allowOrigin возвращает значение Access-Control-Allow-Origin или "".
+// С credentials "*" недопустим, поэтому источник возвращается как есть.
+func (p *corsPolicy) allowOrigin(origin string) string {
+	lower := strings.ToLower(origin)
+	switch {
+	case p.anyOrigin:
+		if p.credentials {
+			return origin
+		}
+		return "*"
+	case p.exact[lower]:
+		return origin
+	}
+	for _, s := range p.suffixes {
+		if strings.HasPrefix(lower, s.scheme) && strings.HasSuffix(lower, s.suffix) &&
+			len(lower) > len(s.scheme)+len(s.suffix) {
+			return origin
+		}
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+func (p *corsPolicy) allowRequestHeaders(requested string) bool {
+	if p.anyHeader {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !p.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+// merge дополняет переопределение маршрута полями основной политики.
+func (c CORSConfig) merge(base CORSConfig) CORSConfig {
+	if c.AllowedOrigins == nil {
+		c.AllowedOrigins = base.AllowedOrigins
+	}
+	if c.AllowedMethods == nil {
+		c.AllowedMethods = base.AllowedMethods
+	}
+	if c.AllowedHeaders == nil {
+		c.AllowedHeaders = base.AllowedHeaders
+	}
+	if c.ExposedHeaders == nil {
+		c.ExposedHeaders = base.ExposedHeaders
+	}
+	if c.MaxAge == 0 {
+		c.MaxAge = base.MaxAge
+	}
+	c.AllowCredentials = c.AllowCredentials || base.AllowCredentials
+	c.Routes = nil
+	return c
+}
+
+type corsHandler struct {
+	router *mux.Router
+	policy *corsPolicy
+	routes map[string]*corsPolicy
+	next   http.Handler
+}
+
+// CORS оборачивает обработчик снаружи роутера: префлайт OPTIONS не
+// совпадает ни с одним маршрутом, поэтому router.Use до него не доходит.
+// Маршрут и его шаблон определяются через router.Match; префлайт к
+// несуществующему маршруту получает 404, к запрещённому — 403.
+func CORS(cfg CORSConfig, router *mux.Router) (func(http.Handler) http.Handler, error) {
+	policy, err := compileCORS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if policy.anyOrigin && policy.credentials {
+		slog.Warn("CORS: credentials are allowed for any origin; every site can make authenticated requests")
+	}
+	routes := make(map[string]*corsPolicy, len(cfg.Routes))
+	for pattern, override := range cfg.Routes {
+		if routes[pattern], err = compileCORS(override.merge(cfg)); err != nil {
+			return nil, fmt.Errorf("cors route %q: %v", pattern, err)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return &corsHandler{router: router, policy: policy, routes: routes, next: next}
+	}, nil
+}
+
+// match ищет маршрут для метода method и политику для него.
+func (h *corsHandler) match(r *http.Request, method string) (*corsPolicy, bool) {
+	probe := *r
+	probe.Method = method
+
+	var match mux.RouteMatch
+	if !h.router.Match(&probe, &match) || match.MatchErr != nil || match.Route == nil {
+		return h.policy, false
+	}
+	template, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return h.policy, true
+	}
+	if policy, ok := h.routes[method+" "+template]; ok {
+		return policy, true
+	}
+	if policy, ok := h.routes[template]; ok {
+		return policy, true
+	}
+	return h.policy, true
+}
+
+func (h *corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	w.Header().Add("Vary", "Origin")
+	if origin == "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	requestedMethod := r.Header.Get("Access-Control-Request-Method")
+	if r.Method == http.MethodOptions && requestedMethod != "" {
+		h.preflight(w, r, origin, requestedMethod)
+		return
+	}
+
+	policy, _ := h.match(r, r.Method)
+	if allowed := policy.allowOrigin(origin); allowed != "" {
+		w.Header().Set("Access-Control-Allow-Origin", allowed)
+		if policy.credentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if policy.exposeHeaders != "" {
+			w.Header().Set("Access-Control-Expose-Headers", policy.exposeHeaders)
+		}
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+func (h *corsHandler) preflight(w http.ResponseWriter, r *http.Request, origin, method string) {
+	header := w.Header()
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	policy, found := h.match(r, method)
+	if !found {
+		var path mux.RouteMatch
+		if !h.router.Match(r, &path) && path.MatchErr != mux.ErrMethodMismatch {
+			problem.Write(w, r, problem.New(problem.RouteNotFound))
+			return
+		}
+	}
+
+	allowed := policy.allowOrigin(origin)
+	requestedHeaders := r.Header.Get("Access-Control-Request-Headers")
+	switch {
+	case allowed == "":
+		problem.Write(w, r, problem.New(problem.OriginNotAllowed))
+		return
+	case !found || !policy.methods[strings.ToUpper(method)]:
+		problem.Write(w, r, problem.New(problem.CORSMethodNotAllowed, strings.ToUpper(method)))
+		return
+	case !policy.allowRequestHeaders(requestedHeaders):
+		problem.Write(w, r, problem.New(problem.CORSHeadersNotAllowed, requestedHeaders))
+		return
+	}
+
+	header.Set("Access-Control-Allow-Origin", allowed)
+	header.Set("Access-Control-Allow-Methods", policy.allowMethods)
+	if policy.anyHeader && requestedHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", requestedHeaders)
+	} else if policy.allowHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", policy.allowHeaders)
+	}
+	if policy.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if policy.maxAge != "" {
+		header.Set("Access-Control-Max-Age", policy.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,272 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go-showcase/problem"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader помечает ответ, повторённый из сохранённого.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+type IdempotencyConfig struct {
+	// TTL — сколько хранится ответ на ключ.
+	TTL time.Duration
+	// Wait — сколько повторный запрос ждёт завершения первого, прежде чем
+	// получить 409; 0 — 409 сразу.
+	Wait time.Duration
+	// MaxKeyLength ограничивает длину Idempotency-Key.
+	MaxKeyLength int
+	// MaxBodySize — тело запроса читается целиком, чтобы сравнить повтор с
+	// оригиналом; больше — 413.
+	MaxBodySize int64
+	// PathPrefix — ключ учитывается только для путей с этим префиксом.
+	PathPrefix string
+}
+
+func DefaultIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		TTL:          24 * time.Hour,
+		Wait:         5 * time.Second,
+		MaxKeyLength: 255,
+		MaxBodySize:  1 << 20,
+		PathPrefix:   "/api/",
+	}
+}
+
+func (c IdempotencyConfig) normalize() IdempotencyConfig {
+	def := DefaultIdempotencyConfig()
+	if c.TTL <= 0 {
+		c.TTL = def.TTL
+	}
+	if c.Wait < 0 {
+		c.Wait = 0
+	}
+	if c.MaxKeyLength <= 0 {
+		c.MaxKeyLength = def.MaxKeyLength
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = def.MaxBodySize
+	}
+	return c
+}
+
+type idempotentRequest struct {
+	fingerprint [sha256.Size]byte
+	// done закрывается, когда первый запрос завершился и ответ сохранён.
+	done    chan struct{}
+	expires time.Time
+
+	status int
+	header http.Header
+	body   []byte
+}
+
+// Idempotency сохраняет ответы на запросы с заголовком Idempotency-Key и
+// повторяет их клиентам, которые повторили запрос после сетевой ошибки.
+// Ключи живут в памяти и видны только своей реплике.
+type Idempotency struct {
+	cfg   IdempotencyConfig
+	scope KeyFunc
+
+	mu        sync.Mutex
+	requests  map[string]*idempotentRequest
+	lastSweep time.Time
+
+	replays    uint64
+	conflicts  uint64
+	mismatches uint64
+}
+
+// NewIdempotency создаёт middleware; scope отделяет ключи разных
+// клиентов (обычно тот же KeyFunc, что и у RateLimiter).
+func NewIdempotency(cfg IdempotencyConfig, scope KeyFunc) *Idempotency {
+	if scope == nil {
+		scope = KeyByIP(nil)
+	}
+	return &Idempotency{
+		cfg:       cfg.normalize(),
+		scope:     scope,
+		requests:  make(map[string]*idempotentRequest),
+		lastSweep: time.Now(),
+	}
+}
+
+// Middleware выполняет запрос с новым ключом и сохраняет ответ на TTL.
+// Повтор с тем же ключом и телом получает сохранённый ответ с заголовком
+// Idempotent-Replayed, повтор с другим методом, путём или телом — 422.
+// Пока первый запрос выполняется, повтор ждёт до Wait, затем получает 409.
+// Ответы 5xx (в том числе 504 по таймауту) тоже сохраняются: изменение
+// могло примениться, и повтор не должен выполнить его второй раз.
+func (id *Idempotency) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || !strings.HasPrefix(r.URL.Path, id.cfg.PathPrefix) ||
+			r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > id.cfg.MaxKeyLength {
+			problem.Write(w, r, problem.New(problem.IdempotencyKeyTooLong, id.cfg.MaxKeyLength))
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, id.cfg.MaxBodySize+1))
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			problem.Write(w, r, problem.New(problem.PayloadTooLarge, maxErr.Limit))
+			return
+		}
+		if err != nil {
+			problem.Write(w, r, problem.New(problem.RequestCanceled))
+			return
+		}
+		if int64(len(body)) > id.cfg.MaxBodySize {
+			problem.Write(w, r, problem.New(problem.PayloadTooLarge, id.cfg.MaxBodySize))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		h := sha256.New()
+		fmt.Fprintf(h, "%s %s?%s\n", r.Method, r.URL.Path, r.URL.RawQuery)
+		h.Write(body)
+		var fingerprint [sha256.Size]byte
+		h.Sum(fingerprint[:0])
+
+		req, owner := id.claim(id.scope(r)+"\n"+key, fingerprint)
+		if owner {
+			id.execute(w, r, next, req)
+			return
+		}
+		if req.fingerprint != fingerprint {
+			id.count(&id.mismatches)
+			problem.Write(w, r, problem.New(problem.IdempotencyKeyReused))
+			return
+		}
+
+		timer := time.NewTimer(id.cfg.Wait)
+		defer timer.Stop()
+		select {
+		case <-req.done:
+			id.count(&id.replays)
+			copyHeader(w.Header(), req.header)
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(req.status)
+			w.Write(req.body)
+
+		case <-timer.C:
+			id.count(&id.conflicts)
+			w.Header().Set("Retry-After", "1")
+			problem.Write(w, r, problem.New(problem.IdempotencyInProgress))
+
+		case <-r.Context().Done():
+		}
+	})
+}
+
+// claim возвращает запись по ключу; owner — запись создана этим вызовом
+// и запрос нужно выполнить.
+func (id *Idempotency) claim(key string, fingerprint [sha256.Size]byte) (*idempotentRequest, bool) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(id.lastSweep) > time.Minute {
+		id.lastSweep = now
+		for k, req := range id.requests {
+			if isClosed(req.done) && now.After(req.expires) {
+				delete(id.requests, k)
+			}
+		}
+	}
+
+	if req, ok := id.requests[key]; ok && !(isClosed(req.done) && now.After(req.expires)) {
+		return req, false
+	}
+	req := &idempotentRequest{
+		fingerprint: fingerprint,
+		done:        make(chan struct{}),
+	}
+	id.requests[key] = req
+	return req, true
+}
+
+// execute выполняет запрос владельца ключа. Ключ остаётся занятым до
+// конца TTL при любом исходе: если обработчик запаниковал или не ответил,
+// потому что клиент ушёл, неизвестно, применилось ли изменение, и повторы
+// получают ошибку вместо нового выполнения.
+func (id *Idempotency) execute(w http.ResponseWriter, r *http.Request, next http.Handler, req *idempotentRequest) {
+	rec := &cacheRecorder{header: make(http.Header)}
+	outcome := problem.Internal
+	defer func() {
+		if req.header == nil {
+			unknown := &cacheRecorder{header: make(http.Header)}
+			problem.Write(unknown, r, problem.New(outcome))
+			req.store(unknown)
+		}
+		id.mu.Lock()
+		req.expires = time.Now().Add(id.cfg.TTL)
+		id.mu.Unlock()
+		close(req.done)
+	}()
+
+	next.ServeHTTP(rec, r)
+
+	if rec.status == 0 {
+		if r.Context().Err() != nil {
+			// Timeout не отвечает ушедшему клиенту, а обработчик может
+			// ещё выполняться.
+			outcome = problem.RequestCanceled
+			return
+		}
+		rec.status = http.StatusOK
+	}
+	req.store(rec)
+
+	copyHeader(w.Header(), rec.header)
+	w.WriteHeader(rec.status)
+	w.Write(rec.body.Bytes())
+}
+
+func (req *idempotentRequest) store(rec *cacheRecorder) {
+	req.status = rec.status
+	req.header = rec.header
+	req.body = rec.body.Bytes()
+}
+
+func (id *Idempotency) count(counter *uint64) {
+	id.mu.Lock()
+	*counter++
+	id.mu.Unlock()
+}
+
+func (id *Idempotency) Stats() map[string]interface{} {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	return map[string]interface{}{
+		"keys":       len(id.requests),
+		"replays":    id.replays,
+		"conflicts":  id.conflicts,
+		"mismatches": id.mismatches,
+	}
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func postWithKey(key, body string) *http.Request {
+	r := quietRequest(http.MethodPost, "/api/users")
+	r.Body = io.NopCloser(strings.NewReader(body))
+	r.Header.Set(IdempotencyKeyHeader, key)
+	return r
+}
+
+func newTestIdempotency() *Idempotency {
+	return NewIdempotency(IdempotencyConfig{Wait: time.Second}, func(*http.Request) string { return "client" })
+}
+
+// TestIdempotencyTimeoutIsNotRetried: изменение могло примениться до 504,
+// поэтому повтор с тем же ключом получает сохранённый 504, а не выполняет
+// его снова.
+func TestIdempotencyTimeoutIsNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	handler := newTestIdempotency().Middleware(Timeout(TimeoutConfig{Default: 20 * time.Millisecond})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+		})))
+	defer close(release)
+
+	first := newCountingWriter()
+	handler.ServeHTTP(first, postWithKey("k1", `{"name":"a"}`))
+	if first.Code != http.StatusGatewayTimeout {
+		t.Fatalf("first: status = %d, want 504", first.Code)
+	}
+
+	retry := newCountingWriter()
+	handler.ServeHTTP(retry, postWithKey("k1", `{"name":"a"}`))
+	if retry.Code != http.StatusGatewayTimeout || retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("retry: status = %d, replayed = %q, want replayed 504",
+			retry.Code, retry.Header().Get(IdempotentReplayedHeader))
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler ran %d times, want 1", n)
+	}
+}
+
+func TestIdempotencyServerErrorIsReplayed(t *testing.T) {
+	var calls atomic.Int32
+	handler := newTestIdempotency().Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	for i := 0; i < 3; i++ {
+		w := newCountingWriter()
+		handler.ServeHTTP(w, postWithKey("k2", ""))
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("attempt %d: status = %d, want 500", i, w.Code)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler ran %d times, want 1", n)
+	}
+}
+
+func TestIdempotencyPanicKeepsKey(t *testing.T) {
+	var calls atomic.Int32
+	id := newTestIdempotency()
+	handler := id.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		panic("boom")
+	}))
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Fatalf("recovered %v, want boom", p)
+			}
+		}()
+		handler.ServeHTTP(newCountingWriter(), postWithKey("k3", ""))
+	}()
+
+	retry := newCountingWriter()
+	handler.ServeHTTP(retry, postWithKey("k3", ""))
+	if retry.Code != http.StatusInternalServerError || !strings.Contains(retry.Body.String(), "internal_error") {
+		t.Fatalf("retry: got %d %q, want internal_error", retry.Code, retry.Body.String())
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler ran %d times, want 1", n)
+	}
+	if keys := id.Stats()["keys"]; keys != 1 {
+		t.Fatalf("keys = %v, want 1", keys)
+	}
+}
+
+func TestIdempotencyReplaysSuccess(t *testing.T) {
+	var calls atomic.Int32
+	handler := newTestIdempotency().Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Location", "/api/users/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	}))
+
+	first := newCountingWriter()
+	handler.ServeHTTP(first, postWithKey("k4", `{"name":"a"}`))
+	if first.Code != http.StatusCreated || first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("first: status = %d, replayed = %q, want fresh 201",
+			first.Code, first.Header().Get(IdempotentReplayedHeader))
+	}
+
+	retry := newCountingWriter()
+	handler.ServeHTTP(retry, postWithKey("k4", `{"name":"a"}`))
+	if retry.Code != http.StatusCreated || retry.Body.String() != `{"id":1}` ||
+		retry.Header().Get("Location") != "/api/users/1" || retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("retry: got %d %q %v, want replayed 201", retry.Code, retry.Body.String(), retry.Header())
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler ran %d times, want 1", n)
+	}
+}
+
+// TestIdempotencyFingerprintMismatch: тот же ключ с другим телом или путём —
+// ошибка клиента, обработчик не выполняется.
+func TestIdempotencyFingerprintMismatch(t *testing.T) {
+	var calls atomic.Int32
+	id := newTestIdempotency()
+	handler := id.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	handler.ServeHTTP(newCountingWriter(), postWithKey("k5", `{"name":"a"}`))
+
+	otherPath := postWithKey("k5", `{"name":"a"}`)
+	otherPath.URL.Path = "/api/users/batch"
+	for name, r := range map[string]*http.Request{
+		"body": postWithKey("k5", `{"name":"b"}`),
+		"path": otherPath,
+	} {
+		w := newCountingWriter()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "idempotency_key_reused") {
+			t.Errorf("other %s: got %d %q, want 422 idempotency_key_reused", name, w.Code, w.Body.String())
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler ran %d times, want 1", n)
+	}
+	if mismatches := id.Stats()["mismatches"]; mismatches != uint64(2) {
+		t.Fatalf("mismatches = %v, want 2", mismatches)
+	}
+}
+
+// TestIdempotencyInProgress: повтор, пока первый запрос выполняется, ждёт
+// Wait и получает 409 с Retry-After.
+func TestIdempotencyInProgress(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	id := NewIdempotency(IdempotencyConfig{Wait: 20 * time.Millisecond}, func(*http.Request) string { return "client" })
+	handler := id.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(newCountingWriter(), postWithKey("k6", ""))
+	}()
+	<-started
+
+	begin := time.Now()
+	retry := newCountingWriter()
+	handler.ServeHTTP(retry, postWithKey("k6", ""))
+	close(release)
+	<-done
+
+	if retry.Code != http.StatusConflict || !strings.Contains(retry.Body.String(), "idempotency_in_progress") {
+		t.Fatalf("retry: got %d %q, want 409 idempotency_in_progress", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Retry-After") != "1" {
+		t.Fatalf("Retry-After = %q, want 1", retry.Header().Get("Retry-After"))
+	}
+	if waited := time.Since(begin); waited < 20*time.Millisecond {
+		t.Fatalf("retry answered after %v, before Wait", waited)
+	}
+}
@@ -0,0 +1,279 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go-showcase/tracing"
+)
+
+type LogFormat string
+
+const (
+	LogJSON LogFormat = "json"
+	LogText LogFormat = "text"
+)
+
+func ParseLogFormat(s string) (LogFormat, error) {
+	switch f := LogFormat(strings.ToLower(strings.TrimSpace(s))); f {
+	case LogJSON, LogText:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q", s)
+	}
+}
+
+type LogConfig struct {
+	Level  slog.Level
+	Format LogFormat
+	// Output — куда писать; nil — os.Stdout.
+	Output io.Writer
+}
+
+func DefaultLogConfig() LogConfig {
+	return LogConfig{Level: slog.LevelInfo, Format: LogJSON}
+}
+
+func NewLogger(cfg LogConfig) *slog.Logger {
+	out := cfg.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+	if cfg.Format == LogText {
+		return slog.New(slog.NewTextHandler(out, opts))
+	}
+	return slog.New(slog.NewJSONHandler(out, opts))
+}
+
+// RequestIDHeader принимается от клиента и возвращается в ответе.
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+type (
+	requestIDKey struct{}
+	loggerKey    struct{}
+	startTimeKey struct{}
+)
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext возвращает логгер запроса (с request_id) или, вне
+// запроса, slog.Default().
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+func StartTimeFromContext(ctx context.Context) (time.Time, bool) {
+	start, ok := ctx.Value(startTimeKey{}).(time.Time)
+	return start, ok
+}
+
+// validRequestID пропускает только короткие ID из безопасных символов:
+// значение попадает в логи и в заголовок ответа.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("-_.:/+=", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newUUID — случайный UUID версии 4.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	// start задаётся только у RequestLogger: он один ставит X-Response-Time.
+	start  time.Time
+	status int
+	size   int
+}
+
+// WriteHeader ставит X-Response-Time до отправки заголовков: после первой
+// записи тела менять их уже поздно. Это время до начала ответа, полная
+// длительность запроса попадает в лог.
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.status == 0 && !rw.start.IsZero() {
+		rw.Header().Set("X-Response-Time", time.Since(rw.start).String())
+	}
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	size, err := rw.ResponseWriter.Write(b)
+	rw.size += size
+	return size, err
+}
+
+// Hijack нужен для апгрейда до WebSocket: без него /ws за логгером не работает.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err == nil && rw.status == 0 {
+		rw.status = http.StatusSwitchingProtocols
+	}
+	return conn, buf, err
+}
+
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// RequestLogger присваивает запросу ID (из X-Request-ID клиента или новый
+// UUID), возвращает его в ответе и кладёт в контекст логгер с request_id,
+// через который пишут обработчики и хаб WebSocket.
+type RequestLogger struct {
+	logger *slog.Logger
+}
+
+func NewRequestLogger(logger *slog.Logger) *RequestLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &RequestLogger{logger: logger}
+}
+
+func (rl *RequestLogger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newUUID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		// И в запросе: ответ могут писать в буфер (кэш, идемпотентность),
+		// где заголовков ответа ещё нет, а ID нужен, например, problem.Write.
+		r.Header.Set(RequestIDHeader, requestID)
+
+		logger := rl.logger.With("request_id", requestID)
+		if sc := tracing.SpanContextFromContext(r.Context()); sc.IsValid() {
+			logger = logger.With("trace_id", sc.TraceID.String(), "span_id", sc.SpanID.String())
+		}
+		ctx := WithRequestID(r.Context(), requestID)
+		ctx = WithLogger(ctx, logger)
+		ctx = context.WithValue(ctx, startTimeKey{}, start)
+		r = r.WithContext(ctx)
+
+		logger.Debug("request started",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote_addr", r.RemoteAddr,
+		)
+
+		rw := &responseWriter{ResponseWriter: w, start: start}
+		next.ServeHTTP(rw, r)
+
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		switch {
+		case rw.status >= 500:
+			level = slog.LevelError
+		case rw.status >= 400:
+			level = slog.LevelWarn
+		}
+		logger.Log(r.Context(), level, "request completed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"query", RedactQuery(r.URL.RawQuery),
+			"status", rw.status,
+			"bytes", rw.size,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"remote_addr", r.RemoteAddr,
+			"user_agent", r.UserAgent(),
+		)
+	})
+}
+
+// sensitiveParams — параметры запроса, значения которых не пишутся в логи
+// и отчёты: например, токен WebSocket (/ws?token=...).
+var sensitiveParams = map[string]bool{
+	"token":        true,
+	"access_token": true,
+	"api_key":      true,
+	"apikey":       true,
+	"password":     true,
+	"secret":       true,
+}
+
+// RedactQuery заменяет значения чувствительных параметров строки запроса
+// на REDACTED; остальные параметры и их порядок не меняются.
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	parts := strings.Split(rawQuery, "&")
+	for i, part := range parts {
+		rawKey, _, hasValue := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		if hasValue && sensitiveParams[strings.ToLower(key)] {
+			parts[i] = rawKey + "=REDACTED"
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// redactedURI — путь и строка запроса r для логов и отчётов.
+func redactedURI(r *http.Request) string {
+	if r.URL.RawQuery == "" {
+		return r.URL.EscapedPath()
+	}
+	return r.URL.EscapedPath() + "?" + RedactQuery(r.URL.RawQuery)
+}
@@ -1,209 +1,12 @@
 package middleware
 
 import (
-	"context"
-	"fmt"
 	"net/http"
-	"sync"
-	"time"
-
-	"golang.org/x/time/rate"
 )
 
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		
-		next.ServeHTTP(w, r)
-	})
-}
-
-type RateLimiter struct {
-	visitors map[string]*rate.Limiter
-	mu       sync.RWMutex
-	rate     rate.Limit
-	burst    int
-}
-
-func NewRateLimiter(r rate.Limit, b int) *RateLimiter {
-	return &RateLimiter{
-		visitors: make(map[string]*rate.Limiter),
-		rate:     r,
-		burst:    b,
-	}
-}
-
-func (rl *RateLimiter) GetLimiter(ip string) *rate.Limiter {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	
-	limiter, exists := rl.visitors[ip]
-	if !exists {
-		limiter = rate.NewLimiter(rl.rate, rl.burst)
-		rl.visitors[ip] = limiter
-	}
-	
-	return limiter
-}
-
-func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
-		limiter := rl.GetLimiter(ip)
-		
-		if !limiter.Allow() {
-			w.Header().Set("Content-Type", "application/json")
-			w.Header().Set("Retry-After", "60")
-			w.WriteHeader(http.StatusTooManyRequests)
-			fmt.Fprintf(w, `{"error": "Rate limit exceeded. Please try again later."}`)
-			return
-		}
-		
-		next.ServeHTTP(w, r)
-	})
-}
-
-func (rl *RateLimiter) CleanupOldVisitors() {
-	ticker := time.NewTicker(5 * time.Minute)
-	go func() {
-		for range ticker.C {
-			rl.mu.Lock()
-			for ip, limiter := range rl.visitors {
-				if limiter.Tokens() == float64(rl.burst) {
-					delete(rl.visitors, ip)
-				}
-			}
-			rl.mu.Unlock()
-		}
-	}()
-}
-
-type RequestLogger struct {
-	mu      sync.Mutex
-	counter int
-}
-
-type responseWriter struct {
-	http.ResponseWriter
-	status int
-	size   int
-}
-
-func (rw *responseWriter) WriteHeader(status int) {
-	rw.status = status
-	rw.ResponseWriter.WriteHeader(status)
-}
-
-func (rw *responseWriter) Write(b []byte) (int, error) {
-	if rw.status == 0 {
-		rw.status = http.StatusOK
-	}
-	size, err := rw.ResponseWriter.Write(b)
-	rw.size += size
-	return size, err
-}
-
-func NewRequestLogger() *RequestLogger {
-	return &RequestLogger{}
-}
-
-func (rl *RequestLogger) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		
-		rl.mu.Lock()
-		rl.counter++
-		requestID := rl.counter
-		rl.mu.Unlock()
-		
-		rw := &responseWriter{ResponseWriter: w, status: 0}
-		
-		fmt.Printf("→ [%d] %s %s %s %s - Started\n", 
-			requestID, time.Now().Format("15:04:05"), r.Method, r.RequestURI, r.RemoteAddr)
-		
-		// Add request ID to context
-		ctx := context.WithValue(r.Context(), "request_id", requestID)
-		ctx = context.WithValue(ctx, "start_time", start)
-		r = r.WithContext(ctx)
-		
-		next.ServeHTTP(rw, r)
-		
-		duration := time.Since(start)
-		fmt.Printf("← [%d] %s %s %s - %d %s (%d bytes) - %v\n", 
-			requestID, time.Now().Format("15:04:05"), r.Method, r.RequestURI, 
-			rw.status, http.StatusText(rw.status), rw.size, duration)
-		
-		// Store duration in context for performance tracking
-		w.Header().Set("X-Response-Time", duration.String())
-	})
-}
-
-func Recovery(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			if err := recover(); err != nil {
-				fmt.Printf("🚨 PANIC RECOVERED: %v\n", err)
-				fmt.Printf("   URL: %s %s\n", r.Method, r.RequestURI)
-				fmt.Printf("   Remote: %s\n", r.RemoteAddr)
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprintf(w, `{"error": "Internal server error occurred"}`)
-			}
-		}()
-		next.ServeHTTP(w, r)
-	})
-}
-
-func SecurityHeaders(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "DENY")
-		w.Header().Set("X-XSS-Protection", "1; mode=block")
-		w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
-		w.Header().Set("Content-Security-Policy", "default-src 'self' 'unsafe-inline' 'unsafe-eval' https://fonts.googleapis.com https://fonts.gstatic.com")
-		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
-		w.Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
-		next.ServeHTTP(w, r)
-	})
-}
-
-func Timeout(duration time.Duration) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx, cancel := context.WithTimeout(r.Context(), duration)
-			defer cancel()
-			
-			r = r.WithContext(ctx)
-			
-			done := make(chan struct{})
-			go func() {
-				next.ServeHTTP(w, r)
-				close(done)
-			}()
-			
-			select {
-			case <-done:
-				return
-			case <-ctx.Done():
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusGatewayTimeout)
-				fmt.Fprintf(w, `{"error": "Request timeout"}`)
-			}
-		})
-	}
-}
-
 func Compress(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Vary", "Accept-Encoding")
 		next.ServeHTTP(w, r)
 	})
 }
-
@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-showcase/problem"
+)
+
+// KeyFunc определяет, чей лимит расходует запрос. Пустая строка — ключ
+// не применим к запросу.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP — лимит на адрес клиента; ip обычно ClientIPResolver.ClientIP.
+func KeyByIP(ip func(r *http.Request) string) KeyFunc {
+	if ip == nil {
+		ip = RemoteIP
+	}
+	return func(r *http.Request) string {
+		return "ip:" + ip(r)
+	}
+}
+
+// KeyByUser — лимит на аутентифицированного пользователя (см. Auth).
+func KeyByUser() KeyFunc {
+	return func(r *http.Request) string {
+		if p, ok := PrincipalFromContext(r.Context()); ok {
+			return "user:" + p.ID
+		}
+		return ""
+	}
+}
+
+// KeyByAPIKey — лимит на API-ключ из заголовка. Ключ учитывается только у
+// аутентифицированных запросов: иначе, подставляя случайные ключи, можно
+// получать новый лимит на каждый запрос. В хранилище попадает хеш ключа.
+func KeyByAPIKey(header string) KeyFunc {
+	return func(r *http.Request) string {
+		key := r.Header.Get(header)
+		if key == "" {
+			return ""
+		}
+		if _, ok := PrincipalFromContext(r.Context()); !ok {
+			return ""
+		}
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:8])
+	}
+}
+
+// FirstKey возвращает первый непустой ключ.
+func FirstKey(keys ...KeyFunc) KeyFunc {
+	return func(r *http.Request) string {
+		for _, key := range keys {
+			if k := key(r); k != "" {
+				return k
+			}
+		}
+		return ""
+	}
+}
+
+// RateLimitPolicy — лимит для группы маршрутов. Tiers переопределяет Limit
+// для аутентифицированных пользователей с данным Principal.Tier. Запросы к
+// маршрутам с Exempt не учитываются, но заголовки RateLimit-* у них тоже
+// есть — с полной квотой Limit.
+type RateLimitPolicy struct {
+	// Name разделяет счётчики: у политик с разными именами они свои.
+	Name   string
+	Limit  Limit
+	Tiers  map[string]Limit
+	Exempt bool
+}
+
+// limitFor выбирает лимит для тарифа. Лимит тарифа без алгоритма
+// считается тем же алгоритмом, что и основной.
+func (p RateLimitPolicy) limitFor(tier string) Limit {
+	limit, ok := p.Tiers[tier]
+	if !ok || tier == "" {
+		return p.Limit
+	}
+	if limit.Algorithm == "" {
+		limit.Algorithm = p.Limit.Algorithm
+	}
+	return limit
+}
+
+type RateLimiter struct {
+	store  RateLimitStore
+	key    KeyFunc
+	policy RateLimitPolicy
+	routes map[string]RateLimitPolicy
+}
+
+func NewRateLimiter(store RateLimitStore, limit Limit, key KeyFunc) *RateLimiter {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	if key == nil {
+		key = KeyByIP(nil)
+	}
+	return &RateLimiter{
+		store:  store,
+		key:    key,
+		policy: RateLimitPolicy{Name: "default", Limit: limit},
+		routes: make(map[string]RateLimitPolicy),
+	}
+}
+
+// Tier задаёт лимит тарифа для маршрутов без собственной политики.
+func (rl *RateLimiter) Tier(tier string, limit Limit) *RateLimiter {
+	if rl.policy.Tiers == nil {
+		rl.policy.Tiers = make(map[string]Limit)
+	}
+	rl.policy.Tiers[tier] = limit
+	return rl
+}
+
+// Route задаёт политику для шаблона маршрута mux ("/api/users/{id}") или
+// для пары "МЕТОД шаблон" ("POST /api/users"); пара важнее шаблона.
+// Политика без Limit берёт общий лимит, но считает запросы отдельно.
+func (rl *RateLimiter) Route(pattern string, policy RateLimitPolicy) *RateLimiter {
+	if policy.Name == "" {
+		policy.Name = pattern
+	}
+	if policy.Limit == (Limit{}) {
+		policy.Limit = rl.policy.Limit
+	}
+	rl.routes[pattern] = policy
+	return rl
+}
+
+// policyFor работает только внутри mux.Router (через router.Use): шаблон
+// маршрута берётся из mux.CurrentRoute.
+func (rl *RateLimiter) policyFor(r *http.Request) RateLimitPolicy {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return rl.policy
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return rl.policy
+	}
+	if policy, ok := rl.routes[r.Method+" "+template]; ok {
+		return policy
+	}
+	if policy, ok := rl.routes[template]; ok {
+		return policy
+	}
+	return rl.policy
+}
+
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := rl.policyFor(r)
+		tier := ""
+		if p, ok := PrincipalFromContext(r.Context()); ok {
+			tier = p.Tier
+		}
+		limit := policy.limitFor(tier)
+
+		key := rl.key(r)
+		if policy.Exempt || key == "" {
+			// Запрос не учитывается, но заголовки есть у каждого ответа:
+			// квота остаётся полной.
+			setRateLimitHeaders(w.Header(), Decision{Limit: limit.burst(), Remaining: limit.burst()})
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		decision, err := rl.store.Take(r.Context(), policy.Name+":"+key, limit)
+		if err != nil {
+			// Хранилище недоступно: лучше пропустить запрос, чем отказать всем.
+			LoggerFromContext(r.Context()).Error("rate limit store unavailable", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		setRateLimitHeaders(h, decision)
+
+		if !decision.Allowed {
+			retryAfter := retryAfterSeconds(decision.RetryAfter)
+			h.Set("Retry-After", strconv.Itoa(retryAfter))
+			problem.Write(w, r, problem.New(problem.RateLimited, retryAfter))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setRateLimitHeaders(h http.Header, d Decision) {
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.ResetAfter)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// retryAfterSeconds округляет вверх: Retry-After в целых секундах, и
+// клиент, повторивший запрос раньше, снова получит 429.
+func retryAfterSeconds(d time.Duration) int {
+	s := ceilSeconds(d)
+	if s < 1 {
+		return 1
+	}
+	return s
+}
@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go-showcase/problem"
+	"go-showcase/tracing"
+)
+
+type takeRequest struct {
+	Key   string `json:"key"`
+	Limit Limit  `json:"limit"`
+}
+
+// HTTPStore обращается к общему сервису лимитов (RateLimitService), так что
+// несколько реплик сервера делят одни и те же счётчики.
+type HTTPStore struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func NewHTTPStore(url, token string) *HTTPStore {
+	return &HTTPStore{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: 500 * time.Millisecond},
+	}
+}
+
+func (s *HTTPStore) Take(ctx context.Context, key string, limit Limit) (decision Decision, err error) {
+	ctx, span := tracing.Start(ctx, "ratelimit.take", tracing.WithKind(tracing.KindClient))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	span.SetAttribute("ratelimit.algorithm", string(limit.algorithm()))
+
+	body, err := json.Marshal(takeRequest{Key: key, Limit: limit})
+	if err != nil {
+		return Decision{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Decision{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return Decision{}, fmt.Errorf("rate limit service: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return Decision{}, fmt.Errorf("rate limit service: %v", err)
+	}
+	return decision, nil
+}
+
+// RateLimitService отдаёт store по HTTP для HTTPStore: POST с
+// {"key", "limit"} возвращает Decision. Если token задан, запросы без
+// "Authorization: Bearer <token>" отклоняются.
+func RateLimitService(store RateLimitStore, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			problem.Write(w, r, problem.New(problem.MethodNotAllowed))
+			return
+		}
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			problem.Write(w, r, problem.New(problem.InvalidToken))
+			return
+		}
+
+		var req takeRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil {
+			problem.Write(w, r, problem.New(problem.MalformedJSON, 0))
+			return
+		}
+		if req.Key == "" {
+			problem.Write(w, r, problem.Invalid(problem.Violation{Field: "key", Rule: problem.RuleRequired}))
+			return
+		}
+
+		decision, err := store.Take(r.Context(), req.Key, req.Limit)
+		if err != nil {
+			problem.Write(w, r, problem.New(problem.RateLimitUnavailable))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(decision)
+	})
+}
@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type Algorithm string
+
+const (
+	// TokenBucket пропускает Burst запросов подряд и восстанавливает
+	// по Rate токенов в секунду.
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindow (sliding window log) пропускает не больше Burst запросов
+	// в любом окне длиной Burst/Rate секунд.
+	SlidingWindow Algorithm = "sliding_window"
+	// GCRA ведёт себя как token bucket, но хранит одну метку времени,
+	// поэтому удобен для сетевых хранилищ.
+	GCRA Algorithm = "gcra"
+)
+
+func ParseAlgorithm(s string) (Algorithm, error) {
+	switch a := Algorithm(strings.ToLower(strings.TrimSpace(s))); a {
+	case TokenBucket, SlidingWindow, GCRA:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unknown rate limit algorithm %q", s)
+	}
+}
+
+type Limit struct {
+	Algorithm Algorithm  `json:"algorithm"`
+	Rate      rate.Limit `json:"rate"`
+	Burst     int        `json:"burst"`
+}
+
+// Decision — результат одной попытки. Длительности в JSON — наносекунды.
+type Decision struct {
+	Allowed   bool `json:"allowed"`
+	Limit     int  `json:"limit"`
+	Remaining int  `json:"remaining"`
+	// ResetAfter — через сколько лимит восстановится полностью.
+	ResetAfter time.Duration `json:"reset_after"`
+	// RetryAfter — через сколько следующий запрос пройдёт; 0, если Allowed.
+	RetryAfter time.Duration `json:"retry_after"`
+}
+
+// RateLimitStore хранит состояние лимитов по ключам. Take атомарно
+// проверяет лимит и, если запрос проходит, учитывает его.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// OpenRateLimitStore создаёт хранилище по адресу: "memory" (или пусто) либо
+// URL сервиса лимитов "http(s)://host:port/path".
+func OpenRateLimitStore(spec, token string) (RateLimitStore, error) {
+	if spec == "" || spec == "memory" {
+		return NewMemoryStore(), nil
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return NewHTTPStore(spec, token), nil
+	default:
+		return nil, fmt.Errorf("unsupported rate limit store %q", spec)
+	}
+}
+
+const (
+	storeShards        = 64
+	storeSweepInterval = time.Minute
+)
+
+// MemoryStore — хранилище в памяти процесса. Ключи разложены по шардам,
+// чтобы запросы с разными ключами не ждали одну блокировку. Записи,
+// лимит которых полностью восстановился, удаляются при очередной уборке.
+type MemoryStore struct {
+	shards [storeShards]memoryShard
+	now    func() time.Time
+}
+
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]*limitState
+	sweepAt time.Time
+}
+
+type limitState struct {
+	algorithm Algorithm
+	// token bucket
+	tokens float64
+	last   time.Time
+	// sliding window: времена пропущенных запросов по возрастанию
+	log []time.Time
+	// GCRA: theoretical arrival time
+	tat time.Time
+
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{now: time.Now}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*limitState)
+	}
+	return s
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	if err := ctx.Err(); err != nil {
+		return Decision{}, err
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := &s.shards[h.Sum32()%storeShards]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := s.now()
+	if now.After(shard.sweepAt) {
+		for k, state := range shard.entries {
+			if now.After(state.expires) {
+				delete(shard.entries, k)
+			}
+		}
+		shard.sweepAt = now.Add(storeSweepInterval)
+	}
+
+	state := shard.entries[key]
+	if state == nil || state.algorithm != limit.algorithm() {
+		state = &limitState{algorithm: limit.algorithm()}
+		shard.entries[key] = state
+	}
+
+	decision := state.take(limit, now)
+	state.expires = now.Add(decision.ResetAfter)
+	return decision, nil
+}
+
+func (l Limit) algorithm() Algorithm {
+	if l.Algorithm == "" {
+		return TokenBucket
+	}
+	return l.Algorithm
+}
+
+func (l Limit) burst() int {
+	if l.Burst <= 0 {
+		return 1
+	}
+	return l.Burst
+}
+
+func (s *limitState) take(limit Limit, now time.Time) Decision {
+	burst := limit.burst()
+	if limit.Rate <= 0 || limit.Rate == rate.Inf {
+		return Decision{Allowed: true, Limit: burst, Remaining: burst}
+	}
+
+	switch limit.algorithm() {
+	case SlidingWindow:
+		return s.takeSlidingWindow(limit.Rate, burst, now)
+	case GCRA:
+		return s.takeGCRA(limit.Rate, burst, now)
+	default:
+		return s.takeTokenBucket(limit.Rate, burst, now)
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+func (s *limitState) takeTokenBucket(r rate.Limit, burst int, now time.Time) Decision {
+	if s.last.IsZero() {
+		s.tokens = float64(burst)
+	} else if elapsed := now.Sub(s.last).Seconds(); elapsed > 0 {
+		s.tokens = math.Min(float64(burst), s.tokens+elapsed*float64(r))
+	}
+	s.last = now
+
+	d := Decision{Limit: burst}
+	if s.tokens >= 1 {
+		s.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - s.tokens) / float64(r))
+	}
+	d.Remaining = int(s.tokens)
+	d.ResetAfter = seconds((float64(burst) - s.tokens) / float64(r))
+	return d
+}
+
+func (s *limitState) takeSlidingWindow(r rate.Limit, burst int, now time.Time) Decision {
+	window := seconds(float64(burst) / float64(r))
+
+	expired := 0
+	for expired < len(s.log) && now.Sub(s.log[expired]) >= window {
+		expired++
+	}
+	s.log = append(s.log[:0], s.log[expired:]...)
+
+	d := Decision{Limit: burst}
+	if len(s.log) < burst {
+		s.log = append(s.log, now)
+		d.Allowed = true
+	} else {
+		d.RetryAfter = s.log[0].Add(window).Sub(now)
+	}
+	d.Remaining = burst - len(s.log)
+	d.ResetAfter = s.log[len(s.log)-1].Add(window).Sub(now)
+	return d
+}
+
+func (s *limitState) takeGCRA(r rate.Limit, burst int, now time.Time) Decision {
+	interval := seconds(1 / float64(r))
+	tolerance := interval * time.Duration(burst)
+
+	tat := s.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+	allowAt := next.Add(-tolerance)
+
+	d := Decision{Limit: burst}
+	if now.Before(allowAt) {
+		d.RetryAfter = allowAt.Sub(now)
+		d.ResetAfter = tat.Sub(now)
+		return d
+	}
+
+	s.tat = next
+	d.Allowed = true
+	d.Remaining = int(now.Sub(allowAt) / interval)
+	d.ResetAfter = next.Sub(now)
+	return d
+}
@@ -0,0 +1,302 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-showcase/problem"
+	"go-showcase/tracing"
+)
+
+// ErrorReport описывает перехваченную панику.
+type ErrorReport struct {
+	Time time.Time `json:"time"`
+	// Source — где случилась паника: "http", "websocket.rpc" и т. п.
+	Source    string `json:"source"`
+	Panic     string `json:"panic"`
+	Stack     string `json:"stack"`
+	RequestID string `json:"request_id,omitempty"`
+	TraceID   string `json:"trace_id,omitempty"`
+	Method    string `json:"method,omitempty"`
+	URI       string `json:"uri,omitempty"`
+}
+
+// ErrorReporter отправляет отчёты о паниках во внешнюю систему.
+// Report вызывается из отдельной горутины и не задерживает ответ.
+type ErrorReporter interface {
+	Report(ctx context.Context, report ErrorReport) error
+	Close() error
+}
+
+// OpenErrorReporter создаёт репортёр по адресу: пусто — без отчётов,
+// "http(s)://collector/errors" — HTTPErrorReporter, путь к файлу или
+// "file:///path" — FileErrorReporter.
+func OpenErrorReporter(spec string) (ErrorReporter, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return NewHTTPErrorReporter(spec), nil
+	case "file":
+		return NewFileErrorReporter(u.Path)
+	case "":
+		return NewFileErrorReporter(spec)
+	default:
+		return nil, fmt.Errorf("unsupported error reporter %q", spec)
+	}
+}
+
+// FileErrorReporter дописывает отчёты в файл по одному JSON на строку.
+type FileErrorReporter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileErrorReporter(path string) (*FileErrorReporter, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileErrorReporter{file: file}, nil
+}
+
+func (r *FileErrorReporter) Report(ctx context.Context, report ErrorReport) error {
+	line, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.file.Write(append(line, '\n'))
+	return err
+}
+
+func (r *FileErrorReporter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// HTTPErrorReporter отправляет каждый отчёт JSON-запросом POST в
+// коллектор — обычно агент на том же хосте.
+type HTTPErrorReporter struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPErrorReporter(endpoint string) *HTTPErrorReporter {
+	return &HTTPErrorReporter{
+		url:    endpoint,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (r *HTTPErrorReporter) Report(ctx context.Context, report ErrorReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("error collector: %s", resp.Status)
+	}
+	return nil
+}
+
+func (r *HTTPErrorReporter) Close() error {
+	r.client.CloseIdleConnections()
+	return nil
+}
+
+// handlerPanic переносит панику из горутины обработчика (см. Timeout)
+// вместе со стеком, на котором она случилась.
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *handlerPanic) String() string {
+	return fmt.Sprint(p.value)
+}
+
+const reportTimeout = 5 * time.Second
+
+// Recoverer перехватывает паники в HTTP-обработчиках и фоновых горутинах:
+// пишет в лог значение и стек вместе с request_id, считает паники по
+// источникам и передаёт отчёт в ErrorReporter.
+type Recoverer struct {
+	reporter ErrorReporter
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	bySource map[string]uint64
+
+	total    atomic.Uint64
+	reported atomic.Uint64
+	failed   atomic.Uint64
+}
+
+// NewRecoverer создаёт Recoverer; reporter может быть nil.
+func NewRecoverer(reporter ErrorReporter) *Recoverer {
+	return &Recoverer{
+		reporter: reporter,
+		bySource: make(map[string]uint64),
+	}
+}
+
+// Middleware отвечает 500 на панику обработчика. Если заголовки уже
+// отправлены, исправить ответ нельзя: соединение обрывается через
+// http.ErrAbortHandler, чтобы клиент не принял обрезанный ответ за целый.
+// Саму http.ErrAbortHandler middleware пропускает дальше как есть.
+func (rc *Recoverer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			report := newErrorReport(r.Context(), "http", p)
+			report.Method, report.URI = r.Method, redactedURI(r)
+			rc.handle(r.Context(), report)
+			if rw.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			problem.Write(w, r, problem.New(problem.Internal))
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// Recover вызывается через defer в начале горутины:
+//
+//	defer rc.Recover(ctx, "websocket.read")
+//
+// и гасит панику, записав её через HandlePanic.
+func (rc *Recoverer) Recover(ctx context.Context, source string) {
+	if p := recover(); p != nil {
+		rc.HandlePanic(ctx, source, p)
+	}
+}
+
+// Go запускает fn в горутине, паника которой не роняет процесс.
+func (rc *Recoverer) Go(ctx context.Context, source string, fn func()) {
+	go func() {
+		defer rc.Recover(ctx, source)
+		fn()
+	}()
+}
+
+// HandlePanic логирует и учитывает уже перехваченную панику p. Вызывать
+// из отложенной функции, чтобы стек указывал на место паники.
+func (rc *Recoverer) HandlePanic(ctx context.Context, source string, p interface{}) {
+	rc.handle(ctx, newErrorReport(ctx, source, p))
+}
+
+func newErrorReport(ctx context.Context, source string, p interface{}) ErrorReport {
+	var stack []byte
+	if hp, ok := p.(*handlerPanic); ok {
+		p, stack = hp.value, hp.stack
+	} else {
+		stack = debug.Stack()
+	}
+
+	report := ErrorReport{
+		Time:      time.Now(),
+		Source:    source,
+		Panic:     fmt.Sprint(p),
+		Stack:     string(stack),
+		RequestID: RequestIDFromContext(ctx),
+	}
+	if sc := tracing.SpanFromContext(ctx).SpanContext(); sc.IsValid() {
+		report.TraceID = sc.TraceID.String()
+	}
+	return report
+}
+
+func (rc *Recoverer) handle(ctx context.Context, report ErrorReport) {
+	args := []interface{}{"source", report.Source, "panic", report.Panic}
+	if report.Method != "" {
+		args = append(args, "method", report.Method, "uri", report.URI)
+	}
+	args = append(args, "stack", report.Stack)
+	LoggerFromContext(ctx).Error("panic recovered", args...)
+
+	rc.total.Add(1)
+	rc.mu.Lock()
+	rc.bySource[report.Source]++
+	rc.mu.Unlock()
+
+	if rc.reporter == nil {
+		return
+	}
+	rc.wg.Add(1)
+	go func() {
+		defer rc.wg.Done()
+		reportCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), reportTimeout)
+		defer cancel()
+		if err := rc.reporter.Report(reportCtx, report); err != nil {
+			rc.failed.Add(1)
+			LoggerFromContext(ctx).Warn("error report failed", "error", err)
+			return
+		}
+		rc.reported.Add(1)
+	}()
+}
+
+// Stats возвращает счётчики паник для /api/metrics.
+func (rc *Recoverer) Stats() map[string]interface{} {
+	rc.mu.Lock()
+	bySource := make(map[string]uint64, len(rc.bySource))
+	for source, n := range rc.bySource {
+		bySource[source] = n
+	}
+	rc.mu.Unlock()
+
+	return map[string]interface{}{
+		"total":           rc.total.Load(),
+		"by_source":       bySource,
+		"reporting":       rc.reporter != nil,
+		"reported":        rc.reported.Load(),
+		"report_failures": rc.failed.Load(),
+	}
+}
+
+// Close дожидается отправки начатых отчётов и закрывает репортёр.
+func (rc *Recoverer) Close() error {
+	rc.wg.Wait()
+	if rc.reporter == nil {
+		return nil
+	}
+	return rc.reporter.Close()
+}
@@ -0,0 +1,287 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-showcase/problem"
+)
+
+// CSPNoncePlaceholder в ContentSecurityPolicy заменяется нонсом запроса.
+const CSPNoncePlaceholder = "{nonce}"
+
+type SecurityHeadersConfig struct {
+	// ContentSecurityPolicy — директивы CSP; пусто — заголовок не ставится.
+	ContentSecurityPolicy string
+	// CSPReportOnly отправляет политику в Content-Security-Policy-Report-Only:
+	// браузер сообщает о нарушениях, но ничего не блокирует.
+	CSPReportOnly bool
+	// CSPReportURI — куда браузер шлёт отчёты (report-uri и report-to).
+	CSPReportURI string
+
+	FrameOptions      string
+	ReferrerPolicy    string
+	PermissionsPolicy string
+	XSSProtection     string
+
+	// HSTS отправляется только по HTTPS: по HTTP браузер его игнорирует,
+	// а за прокси без TLS он закрепил бы HTTPS для хоста, который его не отдаёт.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// Secure решает, пришёл ли запрос по TLS; nil — только r.TLS.
+	Secure func(r *http.Request) bool
+}
+
+func DefaultSecurityHeadersConfig() SecurityHeadersConfig {
+	return SecurityHeadersConfig{
+		ContentSecurityPolicy: "default-src 'self'; " +
+			"script-src 'self' 'nonce-{nonce}'; " +
+			"style-src 'self' 'nonce-{nonce}' https://fonts.googleapis.com; " +
+			"font-src 'self' https://fonts.gstatic.com; " +
+			"img-src 'self' data:; " +
+			"connect-src 'self'; " +
+			"object-src 'none'; " +
+			"base-uri 'self'; " +
+			"form-action 'self'; " +
+			"frame-ancestors 'none'",
+		CSPReportURI:          "/csp-report",
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		PermissionsPolicy:     "geolocation=(), microphone=(), camera=()",
+		XSSProtection:         "1; mode=block",
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+	}
+}
+
+type cspNonceKey struct{}
+
+// CSPNonce возвращает нонс текущего запроса для атрибута nonce у <script>
+// и <style>; пустая строка — SecurityHeaders не подключён.
+func CSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+func newNonce() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+func SecurityHeaders(cfg SecurityHeadersConfig) func(http.Handler) http.Handler {
+	policy := cfg.ContentSecurityPolicy
+	if policy != "" && cfg.CSPReportURI != "" {
+		policy += "; report-uri " + cfg.CSPReportURI + "; report-to csp-endpoint"
+	}
+	cspHeader := "Content-Security-Policy"
+	if cfg.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	withNonce := strings.Contains(policy, CSPNoncePlaceholder)
+
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(cfg.HSTSMaxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	secure := cfg.Secure
+	if secure == nil {
+		secure = func(r *http.Request) bool { return r.TLS != nil }
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			if cfg.FrameOptions != "" {
+				h.Set("X-Frame-Options", cfg.FrameOptions)
+			}
+			if cfg.XSSProtection != "" {
+				h.Set("X-XSS-Protection", cfg.XSSProtection)
+			}
+			if cfg.ReferrerPolicy != "" {
+				h.Set("Referrer-Policy", cfg.ReferrerPolicy)
+			}
+			if cfg.PermissionsPolicy != "" {
+				h.Set("Permissions-Policy", cfg.PermissionsPolicy)
+			}
+			if hsts != "" && secure(r) {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+
+			if policy != "" {
+				value := policy
+				if withNonce {
+					nonce := newNonce()
+					value = strings.ReplaceAll(policy, CSPNoncePlaceholder, nonce)
+					r = r.WithContext(context.WithValue(r.Context(), cspNonceKey{}, nonce))
+				}
+				h.Set(cspHeader, value)
+				if cfg.CSPReportURI != "" {
+					h.Set("Reporting-Endpoints", fmt.Sprintf("csp-endpoint=%q", cfg.CSPReportURI))
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CSPReport — одно нарушение политики в общем виде для обоих форматов:
+// application/csp-report (report-uri) и application/reports+json (report-to).
+type CSPReport struct {
+	DocumentURI        string    `json:"document_uri"`
+	BlockedURI         string    `json:"blocked_uri"`
+	EffectiveDirective string    `json:"effective_directive"`
+	Disposition        string    `json:"disposition,omitempty"`
+	SourceFile         string    `json:"source_file,omitempty"`
+	LineNumber         int       `json:"line_number,omitempty"`
+	UserAgent          string    `json:"user_agent,omitempty"`
+	ReceivedAt         time.Time `json:"received_at"`
+}
+
+const (
+	maxCSPReportBody = 64 * 1024
+	keepCSPReports   = 100
+)
+
+// CSPReports собирает присланные браузерами отчёты и хранит последние.
+type CSPReports struct {
+	mu     sync.Mutex
+	total  int
+	recent []CSPReport
+}
+
+func NewCSPReports() *CSPReports {
+	return &CSPReports{}
+}
+
+func (c *CSPReports) add(report CSPReport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.total++
+	c.recent = append(c.recent, report)
+	if len(c.recent) > keepCSPReports {
+		c.recent = c.recent[len(c.recent)-keepCSPReports:]
+	}
+}
+
+func (c *CSPReports) Stats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	recent := make([]CSPReport, len(c.recent))
+	copy(recent, c.recent)
+	return map[string]interface{}{
+		"total":  c.total,
+		"recent": recent,
+	}
+}
+
+// Handler принимает POST с отчётами от браузера. Принятый отчёт
+// подтверждается 204 без тела.
+func (c *CSPReports) Handler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCSPReportBody))
+	if err != nil {
+		problem.Write(w, r, problem.New(problem.InvalidCSPReport))
+		return
+	}
+
+	reports, err := parseCSPReports(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		problem.Write(w, r, problem.New(problem.InvalidCSPReport))
+		return
+	}
+
+	for _, report := range reports {
+		report.UserAgent = r.UserAgent()
+		report.ReceivedAt = time.Now()
+		c.add(report)
+		LoggerFromContext(r.Context()).Warn("csp violation",
+			"directive", report.EffectiveDirective,
+			"blocked_uri", report.BlockedURI,
+			"document_uri", report.DocumentURI,
+			"disposition", report.Disposition,
+		)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseCSPReports(contentType string, body []byte) ([]CSPReport, error) {
+	if strings.HasPrefix(contentType, "application/reports+json") {
+		var batch []struct {
+			Type string `json:"type"`
+			Body struct {
+				DocumentURL        string `json:"documentURL"`
+				BlockedURL         string `json:"blockedURL"`
+				EffectiveDirective string `json:"effectiveDirective"`
+				Disposition        string `json:"disposition"`
+				SourceFile         string `json:"sourceFile"`
+				LineNumber         int    `json:"lineNumber"`
+			} `json:"body"`
+		}
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, err
+		}
+		var reports []CSPReport
+		for _, item := range batch {
+			if item.Type != "csp-violation" {
+				continue
+			}
+			reports = append(reports, CSPReport{
+				DocumentURI:        item.Body.DocumentURL,
+				BlockedURI:         item.Body.BlockedURL,
+				EffectiveDirective: item.Body.EffectiveDirective,
+				Disposition:        item.Body.Disposition,
+				SourceFile:         item.Body.SourceFile,
+				LineNumber:         item.Body.LineNumber,
+			})
+		}
+		return reports, nil
+	}
+
+	var legacy struct {
+		Report struct {
+			DocumentURI        string `json:"document-uri"`
+			BlockedURI         string `json:"blocked-uri"`
+			EffectiveDirective string `json:"effective-directive"`
+			ViolatedDirective  string `json:"violated-directive"`
+			Disposition        string `json:"disposition"`
+			SourceFile         string `json:"source-file"`
+			LineNumber         int    `json:"line-number"`
+		} `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &legacy); err != nil {
+		return nil, err
+	}
+	report := legacy.Report
+	directive := report.EffectiveDirective
+	if directive == "" {
+		directive = report.ViolatedDirective
+	}
+	return []CSPReport{{
+		DocumentURI:        report.DocumentURI,
+		BlockedURI:         report.BlockedURI,
+		EffectiveDirective: directive,
+		Disposition:        report.Disposition,
+		SourceFile:         report.SourceFile,
+		LineNumber:         report.LineNumber,
+	}}, nil
+}
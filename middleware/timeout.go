@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"go-showcase/problem"
+)
+
+// TimeoutConfig задаёт, сколько может выполняться обработчик.
+type TimeoutConfig struct {
+	Default time.Duration
+	// Routes переопределяет Default для шаблона маршрута mux или пары
+	// "МЕТОД шаблон"; 0 — без таймаута (например, для /ws: апгрейд
+	// требует Hijack, а буферизованный ответ его не поддерживает).
+	Routes map[string]time.Duration
+}
+
+// timeoutFor, как и policyFor у RateLimiter, работает внутри mux.Router.
+func (c TimeoutConfig) timeoutFor(r *http.Request) time.Duration {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return c.Default
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return c.Default
+	}
+	if d, ok := c.Routes[r.Method+" "+template]; ok {
+		return d
+	}
+	if d, ok := c.Routes[template]; ok {
+		return d
+	}
+	return c.Default
+}
+
+// Longest — самый длинный из таймаутов; WriteTimeout сервера должен быть
+// больше, иначе соединение закроется раньше, чем придёт ответ 504.
+func (c TimeoutConfig) Longest() time.Duration {
+	longest := c.Default
+	for _, d := range c.Routes {
+		if d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
+
+// Timeout отменяет контекст запроса по истечении таймаута маршрута и
+// отвечает 504. Обработчик пишет в буфер и работает в отдельной горутине;
+// всё, что он запишет после таймаута, отбрасывается, а ответ клиенту
+// уходит один раз — либо из буфера, либо 504. Паника обработчика
+// передаётся в горутину запроса, где её перехватит Recovery.
+func Timeout(cfg TimeoutConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := cfg.timeoutFor(r)
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{header: w.Header().Clone()}
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						if p != http.ErrAbortHandler {
+							p = &handlerPanic{value: p, stack: debug.Stack()}
+						}
+						panicked <- p
+					}
+				}()
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				dst := w.Header()
+				for k := range dst {
+					if _, ok := tw.header[k]; !ok {
+						delete(dst, k)
+					}
+				}
+				for k, v := range tw.header {
+					dst[k] = v
+				}
+				if tw.status == 0 {
+					tw.status = http.StatusOK
+				}
+				w.WriteHeader(tw.status)
+				w.Write(tw.buf.Bytes())
+
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				if ctx.Err() != context.DeadlineExceeded {
+					// Клиент ушёл сам — отвечать некому.
+					return
+				}
+				LoggerFromContext(r.Context()).Warn("request timed out", "timeout", timeout)
+				problem.Write(w, r, problem.New(problem.RequestTimeout))
+			}
+		})
+	}
+}
+
+// timeoutWriter копит ответ обработчика. После таймаута запись
+// возвращает http.ErrHandlerTimeout.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = status
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(b)
+}
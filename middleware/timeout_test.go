@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// countingWriter считает вызовы WriteHeader: ответ должен уйти ровно один раз.
+type countingWriter struct {
+	*httptest.ResponseRecorder
+	mu          sync.Mutex
+	writeHeader int
+}
+
+func newCountingWriter() *countingWriter {
+	return &countingWriter{ResponseRecorder: httptest.NewRecorder()}
+}
+
+func (w *countingWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	w.writeHeader++
+	w.mu.Unlock()
+	w.ResponseRecorder.WriteHeader(status)
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	if w.writeHeader == 0 {
+		w.writeHeader++
+	}
+	w.mu.Unlock()
+	return w.ResponseRecorder.Write(b)
+}
+
+// quietRequest — запрос с логгером, который ничего не пишет.
+func quietRequest(method, target string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return r.WithContext(WithLogger(r.Context(), logger))
+}
+
+func TestTimeoutDiscardsLateWrites(t *testing.T) {
+	stopped := make(chan struct{})
+	var lateErr error
+	handler := Timeout(TimeoutConfig{Default: 20 * time.Millisecond})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(stopped)
+		w.Header().Set("X-Handler", "1")
+		w.Write([]byte("early"))
+		<-r.Context().Done()
+		// Обработчик не замечает отмену и продолжает писать, пока запись
+		// не начнёт возвращать ошибку.
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+			w.Header().Set("X-Late", "1")
+			w.WriteHeader(http.StatusTeapot)
+			if _, lateErr = w.Write([]byte("late")); lateErr != nil {
+				return
+			}
+		}
+	}))
+
+	w := newCountingWriter()
+	handler.ServeHTTP(w, quietRequest(http.MethodPost, "/slow"))
+	<-stopped
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", w.Code)
+	}
+	if w.writeHeader != 1 {
+		t.Fatalf("WriteHeader called %d times, want 1", w.writeHeader)
+	}
+	if lateErr != http.ErrHandlerTimeout {
+		t.Fatalf("late write error = %v, want http.ErrHandlerTimeout", lateErr)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "early") || strings.Contains(body, "late") {
+		t.Fatalf("handler output leaked into the 504: %q", body)
+	}
+	if !strings.Contains(body, "request_timeout") {
+		t.Fatalf("body is not a request_timeout problem: %q", body)
+	}
+	if w.Header().Get("X-Handler") != "" || w.Header().Get("X-Late") != "" {
+		t.Fatalf("handler headers leaked into the 504: %v", w.Header())
+	}
+}
+
+func TestTimeoutCopiesCompletedResponse(t *testing.T) {
+	handler := Timeout(TimeoutConfig{Default: time.Second})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("done"))
+	}))
+
+	w := newCountingWriter()
+	w.Header().Set("X-Request-ID", "outer")
+	handler.ServeHTTP(w, quietRequest(http.MethodPost, "/fast"))
+
+	if w.Code != http.StatusCreated || w.Body.String() != "done" {
+		t.Fatalf("got %d %q, want 201 \"done\"", w.Code, w.Body.String())
+	}
+	if w.writeHeader != 1 {
+		t.Fatalf("WriteHeader called %d times, want 1", w.writeHeader)
+	}
+	if w.Header().Get("X-Handler") != "1" || w.Header().Get("X-Request-ID") != "outer" {
+		t.Fatalf("headers = %v", w.Header())
+	}
+}
+
+// captureReporter запоминает отчёты Recoverer.
+type captureReporter struct {
+	mu      sync.Mutex
+	reports []ErrorReport
+}
+
+func (c *captureReporter) Report(ctx context.Context, report ErrorReport) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reports = append(c.reports, report)
+	return nil
+}
+
+func (c *captureReporter) Close() error { return nil }
+
+func panickingHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("partial"))
+	panic("boom")
+}
+
+func TestTimeoutForwardsPanic(t *testing.T) {
+	reporter := &captureReporter{}
+	rc := NewRecoverer(reporter)
+	handler := rc.Middleware(Timeout(TimeoutConfig{Default: time.Second})(http.HandlerFunc(panickingHandler)))
+
+	w := newCountingWriter()
+	handler.ServeHTTP(w, quietRequest(http.MethodGet, "/panic"))
+	rc.Close()
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "partial") {
+		t.Fatalf("buffered output leaked: %q", w.Body.String())
+	}
+	if len(reporter.reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reporter.reports))
+	}
+	report := reporter.reports[0]
+	if report.Panic != "boom" {
+		t.Fatalf("panic = %q, want boom", report.Panic)
+	}
+	// Стек — горутины обработчика, а не та, где панику подняли повторно.
+	if !strings.Contains(report.Stack, "panickingHandler") {
+		t.Fatalf("stack does not point at the handler:\n%s", report.Stack)
+	}
+}
+
+func TestTimeoutForwardsAbortHandler(t *testing.T) {
+	handler := Timeout(TimeoutConfig{Default: time.Second})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", p)
+		}
+	}()
+	handler.ServeHTTP(newCountingWriter(), quietRequest(http.MethodGet, "/abort"))
+}
+
+func TestTimeoutPerRoute(t *testing.T) {
+	cfg := TimeoutConfig{
+		Default: time.Second,
+		Routes: map[string]time.Duration{
+			"/api/export":      5 * time.Second,
+			"POST /api/export": 10 * time.Second,
+			"/ws":              0,
+		},
+	}
+	if got := cfg.Longest(); got != 10*time.Second {
+		t.Fatalf("Longest() = %v, want 10s", got)
+	}
+
+	type observed struct {
+		deadline time.Duration
+		buffered bool
+	}
+	var got observed
+	record := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = observed{}
+		if deadline, ok := r.Context().Deadline(); ok {
+			got.deadline = time.Until(deadline).Round(time.Second)
+		}
+		_, got.buffered = w.(*timeoutWriter)
+	})
+
+	router := mux.NewRouter()
+	router.Use(Timeout(cfg))
+	router.Handle("/api/export", record)
+	router.Handle("/api/users", record)
+	router.Handle("/ws", record)
+
+	tests := []struct {
+		method, path string
+		want         observed
+	}{
+		{http.MethodGet, "/api/users", observed{time.Second, true}},
+		{http.MethodGet, "/api/export", observed{5 * time.Second, true}},
+		{http.MethodPost, "/api/export", observed{10 * time.Second, true}},
+		// Без таймаута обработчик получает исходный ResponseWriter, и
+		// апгрейд до WebSocket может сделать Hijack.
+		{http.MethodGet, "/ws", observed{0, false}},
+	}
+	for _, tt := range tests {
+		router.ServeHTTP(newCountingWriter(), quietRequest(tt.method, tt.path))
+		if got != tt.want {
+			t.Errorf("%s %s: got %+v, want %+v", tt.method, tt.path, got, tt.want)
+		}
+	}
+
+	// Вне mux.Router шаблона маршрута нет — действует Default.
+	Timeout(cfg)(record).ServeHTTP(newCountingWriter(), quietRequest(http.MethodGet, "/ws"))
+	if want := (observed{time.Second, true}); got != want {
+		t.Errorf("without router: got %+v, want %+v", got, want)
+	}
+}
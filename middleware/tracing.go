@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"go-showcase/tracing"
+)
+
+// Tracing начинает серверный спан на каждый запрос. Если шлюз прислал
+// traceparent, спан продолжает его трассу. Спан называется по шаблону
+// маршрута ("GET /api/users/{id}"), поэтому middleware подключается
+// через router.Use. clientIP — как и у KeyByIP, обычно
+// ClientIPResolver.ClientIP, чтобы client.address спана совпадал с адресом,
+// по которому считаются лимиты.
+func Tracing(tracer *tracing.Tracer, clientIP func(r *http.Request) string) func(http.Handler) http.Handler {
+	if tracer == nil {
+		tracer = tracing.Default()
+	}
+	if clientIP == nil {
+		clientIP = RemoteIP
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if sc, ok := tracing.Extract(r.Header); ok {
+				ctx = tracing.ContextWithRemoteSpanContext(ctx, sc)
+			}
+
+			name := r.Method
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+					name += " " + template
+				}
+			}
+
+			ctx, span := tracer.Start(ctx, name, tracing.WithKind(tracing.KindServer))
+			defer span.End()
+			span.SetAttribute("http.request.method", r.Method)
+			span.SetAttribute("url.path", r.URL.Path)
+			span.SetAttribute("client.address", clientIP(r))
+			span.SetAttribute("user_agent.original", r.UserAgent())
+			if route != "" {
+				span.SetAttribute("http.route", route)
+			}
+
+			rw := &responseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttribute("http.response.status_code", status)
+			span.SetAttribute("http.response.body.size", rw.size)
+			if status >= 500 {
+				span.SetStatus(tracing.StatusError, strconv.Itoa(status))
+			}
+		})
+	}
+}
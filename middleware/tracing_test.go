@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-showcase/tracing"
+)
+
+// TestTracingClientAddress: за доверенным прокси client.address — адрес
+// клиента из X-Forwarded-For, тот же, по которому считаются лимиты.
+func TestTracingClientAddress(t *testing.T) {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{"trusted proxy", "10.0.0.5:4000", "203.0.113.7"},
+		{"untrusted peer", "198.51.100.9:4000", "198.51.100.9"},
+	}
+	for _, tt := range tests {
+		exporter := tracing.NewMemoryExporter()
+		cfg := tracing.DefaultConfig()
+		cfg.Exporter = exporter
+		tracer := tracing.NewTracer(cfg)
+
+		r := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		r.RemoteAddr = tt.remoteAddr
+		r.Header.Set("X-Forwarded-For", "203.0.113.7")
+		handler := Tracing(tracer, resolver.ClientIP)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		if err := tracer.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		spans := exporter.Spans()
+		if len(spans) != 1 {
+			t.Fatalf("%s: got %d spans, want 1", tt.name, len(spans))
+		}
+		if got := spans[0].Attributes["client.address"]; got != tt.want {
+			t.Errorf("%s: client.address = %v, want %s", tt.name, got, tt.want)
+		}
+		if got := KeyByIP(resolver.ClientIP)(r); got != "ip:"+tt.want {
+			t.Errorf("%s: rate limit key = %s, want ip:%s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"go-showcase/database"
+)
+
+const migrateUsage = `Использование: go-showcase migrate [-db файл] <команда>
+
+Команды:
+  up          применить все недостающие миграции
+  down        откатить последнюю применённую миграцию
+  to <версия> привести схему к версии (0 — откатить всё)
+  status      показать миграции и их состояние
+
+`
+
+// runMigrate выполняет подкоманду migrate и возвращает код выхода.
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dbPath := flags.String("db", os.Getenv("DATABASE_PATH"), "файл базы SQLite (по умолчанию DATABASE_PATH)")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *dbPath == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	db, err := database.Open(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	migrator := database.NewMigrator(db, database.Migrations)
+
+	var done []database.Migration
+	switch cmd := flags.Arg(0); {
+	case cmd == "up" && flags.NArg() == 1:
+		done, err = migrator.Up(ctx)
+	case cmd == "down" && flags.NArg() == 1:
+		done, err = migrator.Down(ctx)
+	case cmd == "to" && flags.NArg() == 2:
+		version, convErr := strconv.Atoi(flags.Arg(1))
+		if convErr != nil {
+			fmt.Fprintf(os.Stderr, "migrate: некорректная версия %q\n", flags.Arg(1))
+			return 2
+		}
+		done, err = migrator.To(ctx, version)
+	case cmd == "status" && flags.NArg() == 1:
+		err = printMigrationStatus(ctx, migrator)
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	// Шаги, выполненные до ошибки, уже сохранены: показываем их в любом случае.
+	if len(done) > 0 {
+		status, statusErr := migrator.Status(ctx)
+		if statusErr == nil {
+			applied := make(map[int]bool, len(status))
+			for _, s := range status {
+				applied[s.Version] = s.Applied
+			}
+			for _, m := range done {
+				if applied[m.Version] {
+					fmt.Printf("↑ применена %s\n", m)
+				} else {
+					fmt.Printf("↓ откачена  %s\n", m)
+				}
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	if len(done) == 0 && flags.Arg(0) != "status" {
+		fmt.Println("Схема уже в нужной версии")
+	}
+	return 0
+}
+
+func printMigrationStatus(ctx context.Context, migrator *database.Migrator) error {
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "МИГРАЦИЯ\tСОСТОЯНИЕ\tПРИМЕНЕНА")
+	version := 0
+	for _, s := range status {
+		if s.Applied {
+			version = s.Version
+			fmt.Fprintf(w, "%s\tприменена\t%s\n", s.Migration, s.AppliedAt.Local().Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Fprintf(w, "%s\tожидает\t-\n", s.Migration)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nВерсия схемы: %d\n", version)
+	return nil
+}
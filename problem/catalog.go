@@ -0,0 +1,107 @@
+package problem
+
+import "net/http"
+
+// Коды ошибок API. Значения — часть контракта: не переименовывать.
+const (
+	// Запрос
+	RouteNotFound        Code = "route_not_found"
+	MethodNotAllowed     Code = "method_not_allowed"
+	EmptyBody            Code = "empty_body"
+	MalformedJSON        Code = "malformed_json"
+	UnsupportedMediaType Code = "unsupported_media_type"
+	PayloadTooLarge      Code = "payload_too_large"
+	ValidationFailed     Code = "validation_failed"
+	InvalidID            Code = "invalid_id"
+
+	// Пользователи
+	UserNotFound Code = "user_not_found"
+	EmailTaken   Code = "email_taken"
+
+	// Доступ
+	InvalidToken           Code = "invalid_token"
+	AuthenticationRequired Code = "authentication_required"
+	AuthNotConfigured      Code = "authentication_not_configured"
+	OriginNotAllowed       Code = "origin_not_allowed"
+	CORSMethodNotAllowed   Code = "cors_method_not_allowed"
+	CORSHeadersNotAllowed  Code = "cors_headers_not_allowed"
+
+	// Лимиты
+	RateLimited           Code = "rate_limited"
+	TooManyConnections    Code = "too_many_connections"
+	TooManyConnectionsIP  Code = "too_many_connections_per_ip"
+	RateLimitUnavailable  Code = "rate_limit_store_unavailable"
+	IdempotencyKeyTooLong Code = "idempotency_key_too_long"
+	IdempotencyKeyReused  Code = "idempotency_key_reused"
+	IdempotencyInProgress Code = "idempotency_in_progress"
+
+	// WebSocket: ошибки в сообщениях type "error"
+	InvalidMessage   Code = "invalid_message"
+	InvalidRequest   Code = "invalid_request"
+	MethodNotFound   Code = "method_not_found"
+	TooManyRequests  Code = "too_many_requests"
+	RecipientOffline Code = "recipient_offline"
+	UnknownTopic     Code = "unknown_topic"
+
+	// Прочее
+	InvalidCSPReport Code = "invalid_csp_report"
+	RequestTimeout   Code = "request_timeout"
+	RequestCanceled  Code = "request_canceled"
+	Internal         Code = "internal_error"
+)
+
+// Правила для FieldError.
+const (
+	RuleRequired     Rule = "required"
+	RuleEmail        Rule = "email"
+	RuleMin          Rule = "min"
+	RuleMax          Rule = "max"
+	RuleMinLength    Rule = "min_length"
+	RuleMaxLength    Rule = "max_length"
+	RuleLength       Rule = "length"
+	RuleMinItems     Rule = "min_items"
+	RuleMaxItems     Rule = "max_items"
+	RuleItemCount    Rule = "item_count"
+	RuleOneOf        Rule = "oneof"
+	RuleFormat       Rule = "format"
+	RuleType         Rule = "type"
+	RuleUnknownField Rule = "unknown_field"
+)
+
+// statuses — HTTP-статус каждого кода. Тексты ошибок лежат в бандлах
+// i18n: problem.<code>.title, problem.<code>.detail (если есть) и rule.<rule>.
+var statuses = map[Code]int{
+	RouteNotFound:          http.StatusNotFound,
+	MethodNotAllowed:       http.StatusMethodNotAllowed,
+	EmptyBody:              http.StatusBadRequest,
+	MalformedJSON:          http.StatusBadRequest,
+	UnsupportedMediaType:   http.StatusUnsupportedMediaType,
+	PayloadTooLarge:        http.StatusRequestEntityTooLarge,
+	ValidationFailed:       http.StatusBadRequest,
+	InvalidID:              http.StatusBadRequest,
+	UserNotFound:           http.StatusNotFound,
+	EmailTaken:             http.StatusConflict,
+	InvalidToken:           http.StatusUnauthorized,
+	AuthenticationRequired: http.StatusUnauthorized,
+	AuthNotConfigured:      http.StatusUnauthorized,
+	OriginNotAllowed:       http.StatusForbidden,
+	CORSMethodNotAllowed:   http.StatusForbidden,
+	CORSHeadersNotAllowed:  http.StatusForbidden,
+	RateLimited:            http.StatusTooManyRequests,
+	TooManyConnections:     http.StatusServiceUnavailable,
+	TooManyConnectionsIP:   http.StatusTooManyRequests,
+	RateLimitUnavailable:   http.StatusServiceUnavailable,
+	IdempotencyKeyTooLong:  http.StatusBadRequest,
+	IdempotencyKeyReused:   http.StatusUnprocessableEntity,
+	IdempotencyInProgress:  http.StatusConflict,
+	InvalidMessage:         http.StatusBadRequest,
+	InvalidRequest:         http.StatusBadRequest,
+	MethodNotFound:         http.StatusNotFound,
+	TooManyRequests:        http.StatusTooManyRequests,
+	RecipientOffline:       http.StatusNotFound,
+	UnknownTopic:           http.StatusBadRequest,
+	InvalidCSPReport:       http.StatusBadRequest,
+	RequestTimeout:         http.StatusGatewayTimeout,
+	RequestCanceled:        http.StatusServiceUnavailable,
+	Internal:               http.StatusInternalServerError,
+}
@@ -0,0 +1,167 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"go-showcase/i18n"
+)
+
+// ContentType — медиатип ответов с ошибкой по RFC 7807.
+const ContentType = "application/problem+json"
+
+// TypePrefix — префикс URI типов ошибок. Ссылка относительная и
+// разрешается от адреса API: GET /problems/{code} описывает тип.
+const TypePrefix = "/problems/"
+
+// requestIDHeader совпадает с middleware.RequestIDHeader: RequestLogger
+// выставляет его в ответе и в запросе до вызова обработчиков. Снаружи
+// RequestLogger (CORS) в запросе — значение клиента, поэтому длина
+// ограничена так же, как там.
+const (
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+// Code — стабильный машиночитаемый код ошибки. Тексты могут меняться и
+// зависят от языка, код — нет: клиенты ветвятся по нему.
+type Code string
+
+// Problem — тело ответа application/problem+json. Title и Detail
+// локализованы, остальные поля от языка не зависят.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError — нарушение в конкретном поле запроса.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    Rule   `json:"code"`
+	Message string `json:"message"`
+}
+
+// Rule — код нарушенного правила поля (required, email, max...).
+type Rule string
+
+// Violation — нарушение правила в поле; текст формируется при ответе на
+// языке клиента.
+type Violation struct {
+	Field string
+	Rule  Rule
+	Args  []interface{}
+	// Message — текст для правила, которого нет в бандлах (например,
+	// зарегистрированного приложением).
+	Message string
+}
+
+// Error — ошибка с кодом из каталога. Обработчики создают её через New и
+// отдают в Write; текст на нужном языке собирается только при ответе.
+type Error struct {
+	Code       Code
+	Args       []interface{}
+	Violations []Violation
+}
+
+func New(code Code, args ...interface{}) *Error {
+	return &Error{Code: code, Args: args}
+}
+
+// Invalid — ошибка validation_failed с нарушениями по полям.
+func Invalid(violations ...Violation) *Error {
+	return &Error{Code: ValidationFailed, Violations: violations}
+}
+
+func (e *Error) Status() int {
+	if status, ok := statuses[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Error возвращает английский текст — для логов и ошибок вне HTTP.
+func (e *Error) Error() string {
+	p := e.Problem(i18n.Lang(i18n.DefaultLanguage))
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Detail
+}
+
+// Problem собирает тело ответа на языке принтера.
+func (e *Error) Problem(t *i18n.Printer) Problem {
+	code := e.Code
+	if _, ok := statuses[code]; !ok {
+		code = Internal
+	}
+	p := Problem{
+		Type:   TypePrefix + string(code),
+		Title:  t.T("problem." + string(code) + ".title"),
+		Status: e.Status(),
+		Code:   code,
+	}
+	if key := "problem." + string(code) + ".detail"; t.Has(key) {
+		p.Detail = t.T(key, e.Args...)
+	}
+	for _, v := range e.Violations {
+		key := "rule." + string(v.Rule)
+		msg := t.T(key, v.Args...)
+		if msg == key && v.Message != "" {
+			msg = v.Message
+		}
+		p.Errors = append(p.Errors, FieldError{Field: v.Field, Code: v.Rule, Message: msg})
+	}
+	return p
+}
+
+// Write отвечает ошибкой err в формате RFC 7807 на языке запроса (см.
+// i18n.For). Ошибки не из каталога отдаются как internal_error: их текст
+// клиенту не показывается.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = New(Internal)
+	}
+	t := i18n.For(r)
+	p := e.Problem(t)
+	p.Instance = r.URL.Path
+	p.RequestID = w.Header().Get(requestIDHeader)
+	if p.RequestID == "" && len(r.Header.Get(requestIDHeader)) <= maxRequestIDLength {
+		p.RequestID = r.Header.Get(requestIDHeader)
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", ContentType)
+	h.Set("Content-Language", t.Lang)
+	i18n.VaryLanguage(h)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Describe отдаёт описание типа ошибки по адресу из поля type.
+func Describe(w http.ResponseWriter, r *http.Request) {
+	code := Code(strings.TrimPrefix(r.URL.Path, TypePrefix))
+	status, ok := statuses[code]
+	if !ok {
+		Write(w, r, New(RouteNotFound))
+		return
+	}
+	t := i18n.For(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Language", t.Lang)
+	i18n.VaryLanguage(w.Header())
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":   TypePrefix + string(code),
+		"code":   code,
+		"status": status,
+		"title":  t.T("problem." + string(code) + ".title"),
+	})
+}
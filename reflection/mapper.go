@@ -0,0 +1,403 @@
+package reflection
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DBTag — тег с именем столбца.
+const DBTag = "db"
+
+// Column — поле структуры, связанное со столбцом таблицы.
+type Column struct {
+	Name string
+	// Index — путь к полю для reflect.Value.FieldByIndex, через встроенные
+	// структуры.
+	Index []int
+	Type  reflect.Type
+	// PK — первичный ключ (`db:"id,pk"`): нулевой ключ не попадает в INSERT,
+	// чтобы его выдала база, и по нему строится WHERE в UPDATE.
+	PK bool
+}
+
+// StructMap — столбцы структуры в порядке полей:
+//
+//	type Timestamps struct {
+//		CreatedAt time.Time `db:"created_at"`
+//	}
+//
+//	type User struct {
+//		ID      int            `db:"id,pk"`
+//		Name    string         `db:"name"`
+//		Age     *int           `db:"age"`     // NULL — nil
+//		Country sql.NullString `db:"country"` // или sql.Null*
+//		Timestamps
+//	}
+//
+// Отображаются только поля с тегом db; поля встроенных структур без тега
+// поднимаются на уровень внешней, как в encoding/json. time.Time, типы
+// с sql.Scanner или driver.Valuer и указатели — обычные столбцы: NULL в них
+// пишет и читает database/sql. Разбор каждого типа выполняется один раз.
+type StructMap struct {
+	Type    reflect.Type
+	Columns []Column
+
+	pk     int
+	byName map[string]int
+}
+
+var structMaps sync.Map // reflect.Type → *StructMap
+
+// MapOf возвращает столбцы типа значения v: структуры, указателя на неё или
+// слайса таких значений.
+func MapOf(v interface{}) (*StructMap, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, errors.New("reflection: MapOf(nil)")
+	}
+	t = indirect(t)
+	if t.Kind() == reflect.Slice {
+		t = indirect(t.Elem())
+	}
+	return MapStruct(t)
+}
+
+// MapStruct возвращает столбцы структуры t.
+func MapStruct(t reflect.Type) (*StructMap, error) {
+	if m, ok := structMaps.Load(t); ok {
+		return m.(*StructMap), nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("reflection: %s is not a struct", t)
+	}
+	m := &StructMap{Type: t, pk: -1, byName: make(map[string]int)}
+	if err := m.addFields(t, nil); err != nil {
+		return nil, err
+	}
+	actual, _ := structMaps.LoadOrStore(t, m)
+	return actual.(*StructMap), nil
+}
+
+func (m *StructMap) addFields(t reflect.Type, index []int) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup(DBTag)
+		if tag == "-" {
+			continue
+		}
+		path := append(append([]int(nil), index...), i)
+
+		if !hasTag {
+			ft := indirect(f.Type)
+			if f.Anonymous && ft.Kind() == reflect.Struct && !isColumnType(ft) {
+				if err := m.addFields(ft, path); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			return fmt.Errorf("reflection: %s.%s: db tag on unexported field", m.Type, f.Name)
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		if _, dup := m.byName[name]; dup {
+			return fmt.Errorf("reflection: %s: duplicate column %q", m.Type, name)
+		}
+		col := Column{Name: name, Index: path, Type: f.Type, PK: opts == "pk"}
+		if col.PK {
+			if m.pk >= 0 {
+				return fmt.Errorf("reflection: %s: more than one pk column", m.Type)
+			}
+			m.pk = len(m.Columns)
+		}
+		m.byName[name] = len(m.Columns)
+		m.Columns = append(m.Columns, col)
+	}
+	return nil
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// isColumnType — структура, которую database/sql читает и пишет целиком.
+func isColumnType(t reflect.Type) bool {
+	return t == timeType ||
+		reflect.PointerTo(t).Implements(scannerType) ||
+		t.Implements(valuerType)
+}
+
+// Names возвращает имена всех столбцов.
+func (m *StructMap) Names() []string {
+	names := make([]string, len(m.Columns))
+	for i, c := range m.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// PK возвращает столбец первичного ключа.
+func (m *StructMap) PK() (Column, bool) {
+	if m.pk < 0 {
+		return Column{}, false
+	}
+	return m.Columns[m.pk], true
+}
+
+// Column возвращает столбец по имени.
+func (m *StructMap) Column(name string) (Column, bool) {
+	i, ok := m.byName[name]
+	if !ok {
+		return Column{}, false
+	}
+	return m.Columns[i], true
+}
+
+// Pointers возвращает адреса полей v для Scan в порядке columns. v должен
+// быть адресуемой структурой; nil-указатели встроенных структур создаются.
+func (m *StructMap) Pointers(v reflect.Value, columns []string) ([]interface{}, error) {
+	ptrs := make([]interface{}, len(columns))
+	for i, name := range columns {
+		c, ok := m.Column(name)
+		if !ok {
+			return nil, fmt.Errorf("reflection: %s has no column %q", m.Type, name)
+		}
+		f, err := fieldAlloc(v, c.Index)
+		if err != nil {
+			return nil, err
+		}
+		ptrs[i] = f.Addr().Interface()
+	}
+	return ptrs, nil
+}
+
+// fieldAlloc — FieldByIndex, создающий nil-указатели на пути к полю.
+// Указатель на неэкспортируемую встроенную структуру создать нельзя, как и
+// в encoding/json, — это ошибка.
+func fieldAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("reflection: cannot set embedded pointer to unexported struct %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// Values возвращает значения полей v в порядке columns. Поле внутри
+// nil-указателя на встроенную структуру даёт NULL.
+func (m *StructMap) Values(v reflect.Value, columns []string) ([]interface{}, error) {
+	values := make([]interface{}, len(columns))
+	for i, name := range columns {
+		c, ok := m.Column(name)
+		if !ok {
+			return nil, fmt.Errorf("reflection: %s has no column %q", m.Type, name)
+		}
+		if f, err := v.FieldByIndexErr(c.Index); err == nil {
+			values[i] = f.Interface()
+		}
+	}
+	return values, nil
+}
+
+// PKValue возвращает значение первичного ключа v. Ключ внутри nil-указателя
+// на встроенную структуру — ошибка: по NULL строку не найти, и запрос просто
+// не затронул бы ни одной.
+func (m *StructMap) PKValue(v reflect.Value) (interface{}, error) {
+	pk, ok := m.PK()
+	if !ok {
+		return nil, fmt.Errorf("reflection: %s has no pk column", m.Type)
+	}
+	f, err := v.FieldByIndexErr(pk.Index)
+	if err != nil {
+		return nil, fmt.Errorf("reflection: %s: pk %s is inside a nil embedded struct", m.Type, pk.Name)
+	}
+	return f.Interface(), nil
+}
+
+// SetPK записывает в первичный ключ v значение id, выданное базой.
+// nil-указатели встроенных структур на пути к ключу создаются, ключи не
+// целых типов не меняются. v должен быть адресуемой структурой.
+func (m *StructMap) SetPK(v reflect.Value, id int64) error {
+	pk, ok := m.PK()
+	if !ok {
+		return fmt.Errorf("reflection: %s has no pk column", m.Type)
+	}
+	switch pk.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return nil
+	}
+	key, err := fieldAlloc(v, pk.Index)
+	if err != nil {
+		return err
+	}
+	if key.CanInt() {
+		key.SetInt(id)
+	} else {
+		key.SetUint(uint64(id))
+	}
+	return nil
+}
+
+// Scanner — *sql.Row или *sql.Rows.
+type Scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// ScanRow читает текущую строку в структуру по указателю dest. columns —
+// столбцы запроса в порядке SELECT.
+func ScanRow(s Scanner, columns []string, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("reflection: ScanRow expects a non-nil pointer, got %T", dest)
+	}
+	m, err := MapStruct(v.Type().Elem())
+	if err != nil {
+		return err
+	}
+	ptrs, err := m.Pointers(v.Elem(), columns)
+	if err != nil {
+		return err
+	}
+	return s.Scan(ptrs...)
+}
+
+// ScanRows читает все строки rows в слайс по указателю dest ([]T или []*T);
+// столбцы сопоставляются по именам из rows.Columns. rows не закрывается.
+func ScanRows(rows *sql.Rows, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("reflection: ScanRows expects a pointer to a slice, got %T", dest)
+	}
+	slice := v.Elem()
+	elem := slice.Type().Elem()
+	byPtr := elem.Kind() == reflect.Ptr
+	m, err := MapStruct(indirect(elem))
+	if err != nil {
+		return err
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		row := reflect.New(m.Type)
+		ptrs, err := m.Pointers(row.Elem(), columns)
+		if err != nil {
+			return err
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		if byPtr {
+			slice.Set(reflect.Append(slice, row))
+		} else {
+			slice.Set(reflect.Append(slice, row.Elem()))
+		}
+	}
+	return rows.Err()
+}
+
+// InsertSQL строит INSERT всех столбцов src. Нулевой первичный ключ
+// пропускается, чтобы его выдала база; ключ внутри nil-указателя на
+// встроенную структуру тоже считается нулевым.
+func InsertSQL(table string, src interface{}) (string, []interface{}, error) {
+	m, v, err := mapValue(src)
+	if err != nil {
+		return "", nil, err
+	}
+	var columns []string
+	for _, c := range m.Columns {
+		if c.PK {
+			if f, err := v.FieldByIndexErr(c.Index); err != nil || f.IsZero() {
+				continue
+			}
+		}
+		columns = append(columns, c.Name)
+	}
+	if len(columns) == 0 {
+		return "", nil, fmt.Errorf("reflection: %s has no columns to insert", m.Type)
+	}
+	args, err := m.Values(v, columns)
+	if err != nil {
+		return "", nil, err
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table, strings.Join(columns, ", "), placeholders(len(columns)))
+	return query, args, nil
+}
+
+// UpdateSQL строит UPDATE строки с первичным ключом src. Без columns
+// обновляются все столбцы, кроме ключа. Ключ внутри nil-указателя на
+// встроенную структуру — ошибка (см. PKValue).
+func UpdateSQL(table string, src interface{}, columns ...string) (string, []interface{}, error) {
+	m, v, err := mapValue(src)
+	if err != nil {
+		return "", nil, err
+	}
+	pk, ok := m.PK()
+	if !ok {
+		return "", nil, fmt.Errorf("reflection: %s has no pk column", m.Type)
+	}
+	if len(columns) == 0 {
+		for _, c := range m.Columns {
+			if !c.PK {
+				columns = append(columns, c.Name)
+			}
+		}
+	}
+	key, err := m.PKValue(v)
+	if err != nil {
+		return "", nil, err
+	}
+	args, err := m.Values(v, columns)
+	if err != nil {
+		return "", nil, err
+	}
+	args = append(args, key)
+	set := make([]string, len(columns))
+	for i, name := range columns {
+		set[i] = name + " = ?"
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?", table, strings.Join(set, ", "), pk.Name)
+	return query, args, nil
+}
+
+func mapValue(src interface{}) (*StructMap, reflect.Value, error) {
+	v := reflect.ValueOf(src)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, v, fmt.Errorf("reflection: expected a struct, got %T", src)
+	}
+	m, err := MapStruct(v.Type())
+	return m, v, err
+}
+
+func placeholders(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n-1) + "?"
+}
@@ -0,0 +1,111 @@
+package reflection
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type insertKey struct {
+	ID int `db:"id,pk"`
+}
+
+type insertRow struct {
+	*insertKey
+	Name string `db:"name"`
+}
+
+type onlyKey struct {
+	ID int `db:"id,pk"`
+}
+
+func TestInsertSQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   interface{}
+		query string
+		args  []interface{}
+	}{
+		{"nil embedded pk", &insertRow{Name: "a"},
+			"INSERT INTO t (name) VALUES (?)", []interface{}{"a"}},
+		{"zero pk", &insertRow{insertKey: &insertKey{}, Name: "a"},
+			"INSERT INTO t (name) VALUES (?)", []interface{}{"a"}},
+		{"explicit pk", &insertRow{insertKey: &insertKey{ID: 7}, Name: "a"},
+			"INSERT INTO t (id, name) VALUES (?, ?)", []interface{}{7, "a"}},
+	}
+	for _, tt := range tests {
+		query, args, err := InsertSQL("t", tt.src)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if query != tt.query || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: got %q %v, want %q %v", tt.name, query, args, tt.query, tt.args)
+		}
+	}
+}
+
+func TestInsertSQLNoColumns(t *testing.T) {
+	_, _, err := InsertSQL("t", &onlyKey{})
+	if err == nil || !strings.Contains(err.Error(), "no columns to insert") {
+		t.Fatalf("err = %v, want no columns to insert", err)
+	}
+}
+
+func TestUpdateSQL(t *testing.T) {
+	query, args, err := UpdateSQL("t", &insertRow{insertKey: &insertKey{ID: 7}, Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "UPDATE t SET name = ? WHERE id = ?"; query != want || !reflect.DeepEqual(args, []interface{}{"a", 7}) {
+		t.Errorf("got %q %v, want %q [a 7]", query, args, want)
+	}
+
+	// Без ключа UPDATE не совпал бы ни с одной строкой: это ошибка, а не
+	// WHERE id = NULL.
+	_, _, err = UpdateSQL("t", &insertRow{Name: "a"})
+	if err == nil || !strings.Contains(err.Error(), "nil embedded struct") {
+		t.Fatalf("nil embedded pk: err = %v, want nil embedded struct", err)
+	}
+}
+
+// RowKey экспортируется: reflect может создать указатель на встроенную
+// структуру, только если её тип экспортируемый.
+type RowKey struct {
+	ID int64 `db:"id,pk"`
+}
+
+type keyedRow struct {
+	*RowKey
+	Name string `db:"name"`
+}
+
+func TestSetPK(t *testing.T) {
+	m, err := MapStruct(reflect.TypeOf(keyedRow{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := &keyedRow{Name: "a"}
+	v := reflect.ValueOf(row).Elem()
+	if _, err := m.PKValue(v); err == nil {
+		t.Fatal("PKValue of a nil embedded pk succeeded")
+	}
+	if err := m.SetPK(v, 42); err != nil {
+		t.Fatal(err)
+	}
+	if row.RowKey == nil || row.ID != 42 {
+		t.Fatalf("SetPK: got %+v, want embedded key 42", row.RowKey)
+	}
+	if key, err := m.PKValue(v); err != nil || key != int64(42) {
+		t.Fatalf("PKValue = %v, %v; want 42", key, err)
+	}
+
+	// Указатель на неэкспортируемую структуру не создать: ошибка, а не паника.
+	unexported, err := MapStruct(reflect.TypeOf(insertRow{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = unexported.SetPK(reflect.ValueOf(&insertRow{}).Elem(), 1)
+	if err == nil || !strings.Contains(err.Error(), "unexported struct") {
+		t.Fatalf("SetPK through an unexported embedded pointer: err = %v", err)
+	}
+}
@@ -35,6 +35,11 @@ func DemoReflection() {
 	calculator := Calculator{}
 	callMethod(calculator, "Add", 5, 3)
 	callMethod(calculator, "Multiply", 4, 7)
+	
+	fmt.Println("\nПроверка по тегам validate:")
+	for _, err := range Validate(Person{Age: 200}) {
+		fmt.Printf("  %s: правило %s %s (значение: %v)\n", err.Field, err.Rule, err.Param, err.Value)
+	}
 }
 
 func inspectStruct(s interface{}) {
@@ -0,0 +1,414 @@
+package reflection
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"go-showcase/interfaces"
+)
+
+// TagName — тег с правилами проверки поля.
+const TagName = "validate"
+
+// emailRegexp компилируется один раз; адрес сверяется в нижнем регистре.
+var emailRegexp = regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}$`)
+
+// RuleFunc проверяет значение поля (указатели уже разыменованы). param —
+// текст после "=" в теге, пустой, если его нет.
+type RuleFunc func(v reflect.Value, param string) bool
+
+type customRule struct {
+	fn  RuleFunc
+	msg string
+}
+
+// Validator проверяет структуры по тегам validate:
+//
+//	Name  string   `json:"name" validate:"required,min=2,max=100"`
+//	Email string   `json:"email" validate:"omitempty,email"`
+//	Role  string   `json:"role" validate:"oneof=admin user"`
+//	Tags  []string `json:"tags" validate:"max=10,dive,required,max=32"`
+//	Code  string   `json:"code" validate:"regex=^[A-Z]{2},[0-9]+$"`
+//
+// Правила перечисляются через запятую. omitempty пропускает остальные
+// правила для нулевого значения, nil-указатель без required не проверяется
+// вовсе. min, max и len сравнивают числа со значением, а строки и коллекции —
+// по длине (строки — в символах). Правила после dive относятся к элементам
+// слайса, массива или map. regex забирает остаток тега вместе с запятыми,
+// поэтому идёт последним.
+//
+// Вложенные структуры и указатели на них проверяются всегда, структуры в
+// коллекциях — только после dive. Имена полей в ошибках берутся из тега
+// json: "users[2].email". Ошибка описывается кодом правила (Rule) и его
+// параметром (Param), а текст на языке клиента собирает problem/i18n; Msg
+// заполняется только у правил из RegisterRule. Ошибка в самом теге — ошибка программы, поэтому
+// Validate паникует при первой проверке такого типа.
+type Validator struct {
+	mu     sync.RWMutex
+	custom map[string]customRule
+	types  sync.Map // reflect.Type → *structSpec
+}
+
+// NewValidator создаёт валидатор со встроенными правилами.
+func NewValidator() *Validator {
+	return &Validator{custom: make(map[string]customRule)}
+}
+
+var defaultValidator = NewValidator()
+
+// Validate проверяет s валидатором по умолчанию.
+func Validate(s interface{}) []interfaces.ValidationError {
+	return defaultValidator.Validate(s)
+}
+
+// RegisterRule добавляет правило в валидатор по умолчанию.
+func RegisterRule(name string, fn RuleFunc, msg string) {
+	defaultValidator.RegisterRule(name, fn, msg)
+}
+
+var builtinRules = map[string]bool{
+	"required": true, "omitempty": true, "dive": true,
+	"min": true, "max": true, "len": true,
+	"email": true, "oneof": true, "regex": true,
+}
+
+// RegisterRule добавляет правило name; msg попадает в Msg ошибки — текст на
+// случай, если в бандлах нет перевода для rule.<name>. Встроенные
+// правила переопределить нельзя, своё правило можно заменить.
+func (val *Validator) RegisterRule(name string, fn RuleFunc, msg string) {
+	if name == "" || strings.ContainsAny(name, ",= ") || builtinRules[name] {
+		panic(fmt.Sprintf("reflection: invalid rule name %q", name))
+	}
+	if fn == nil {
+		panic("reflection: nil RuleFunc for rule " + name)
+	}
+	val.mu.Lock()
+	val.custom[name] = customRule{fn: fn, msg: msg}
+	val.mu.Unlock()
+	// Правила связываются с полями при разборе типа, поэтому разобранные
+	// типы нужно разобрать заново.
+	val.types.Range(func(key, _ interface{}) bool {
+		val.types.Delete(key)
+		return true
+	})
+}
+
+// Validate проверяет структуру или указатель на неё и возвращает нарушения
+// в порядке полей; nil — если их нет.
+func (val *Validator) Validate(s interface{}) []interfaces.ValidationError {
+	v := reflect.ValueOf(s)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("reflection: Validate expects a struct, got %T", s))
+	}
+	var errs []interfaces.ValidationError
+	val.validateStruct(v, "", &errs)
+	return errs
+}
+
+type rule struct {
+	name  string
+	param string
+	msg   string
+	check func(v reflect.Value) bool
+}
+
+// chain — правила одного уровня: самого поля или, после dive, его элементов.
+type chain struct {
+	omitempty bool
+	required  bool
+	rules     []rule
+	dive      *chain
+}
+
+type fieldSpec struct {
+	index  int
+	name   string
+	inline bool // встроенная структура без имени в json: поля на том же уровне
+	chain  chain
+}
+
+type structSpec struct {
+	fields []fieldSpec
+}
+
+func (val *Validator) spec(t reflect.Type) *structSpec {
+	if s, ok := val.types.Load(t); ok {
+		return s.(*structSpec)
+	}
+	s := val.parseStruct(t)
+	actual, _ := val.types.LoadOrStore(t, s)
+	return actual.(*structSpec)
+}
+
+func (val *Validator) parseStruct(t reflect.Type) *structSpec {
+	s := &structSpec{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get(TagName)
+		if f.PkgPath != "" || tag == "-" {
+			continue
+		}
+		name, inline := fieldName(f)
+		c, err := val.parseChain(f.Type, splitTag(tag))
+		if err != nil {
+			panic(fmt.Sprintf("reflection: %s.%s: %v", t, f.Name, err))
+		}
+		s.fields = append(s.fields, fieldSpec{index: i, name: name, inline: inline, chain: *c})
+	}
+	return s
+}
+
+// fieldName возвращает имя поля из тега json.
+func fieldName(f reflect.StructField) (string, bool) {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		name = ""
+	}
+	if name == "" && f.Anonymous && indirect(f.Type).Kind() == reflect.Struct {
+		return "", true
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, false
+}
+
+// splitTag делит тег на правила; regex забирает остаток тега целиком.
+func splitTag(tag string) []string {
+	var parts []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(parts, tag)
+		}
+		part, rest, _ := strings.Cut(tag, ",")
+		parts = append(parts, strings.TrimSpace(part))
+		tag = strings.TrimSpace(rest)
+	}
+	return parts
+}
+
+func (val *Validator) parseChain(t reflect.Type, parts []string) (*chain, error) {
+	c := &chain{}
+	t = indirect(t)
+	for i, part := range parts {
+		name, param, _ := strings.Cut(part, "=")
+		switch name {
+		case "":
+			continue
+		case "omitempty":
+			c.omitempty = true
+		case "required":
+			c.required = true
+			c.rules = append(c.rules, rule{name: name, check: isPresent})
+		case "dive":
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+			default:
+				return nil, fmt.Errorf("dive on %s", t)
+			}
+			elem, err := val.parseChain(t.Elem(), parts[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			c.dive = elem
+			return c, nil
+		default:
+			r, err := val.parseRule(t, name, param)
+			if err != nil {
+				return nil, err
+			}
+			c.rules = append(c.rules, r)
+		}
+	}
+	return c, nil
+}
+
+func (val *Validator) parseRule(t reflect.Type, name, param string) (rule, error) {
+	r := rule{name: name, param: param}
+	switch name {
+	case "min", "max", "len":
+		return sizeRule(t, r)
+
+	case "email":
+		if t.Kind() != reflect.String {
+			return r, fmt.Errorf("email on %s", t)
+		}
+		r.check = func(v reflect.Value) bool {
+			return emailRegexp.MatchString(strings.ToLower(v.String()))
+		}
+
+	case "oneof":
+		allowed := strings.Fields(param)
+		if len(allowed) == 0 {
+			return r, fmt.Errorf("oneof without values")
+		}
+		set := make(map[string]bool, len(allowed))
+		for _, a := range allowed {
+			set[a] = true
+		}
+		r.check = func(v reflect.Value) bool {
+			return set[fmt.Sprint(v.Interface())]
+		}
+
+	case "regex":
+		if t.Kind() != reflect.String {
+			return r, fmt.Errorf("regex on %s", t)
+		}
+		re, err := regexp.Compile(param)
+		if err != nil {
+			return r, err
+		}
+		r.check = func(v reflect.Value) bool {
+			return re.MatchString(v.String())
+		}
+
+	default:
+		val.mu.RLock()
+		custom, ok := val.custom[name]
+		val.mu.RUnlock()
+		if !ok {
+			return r, fmt.Errorf("unknown rule %q", name)
+		}
+		r.msg = custom.msg
+		r.check = func(v reflect.Value) bool {
+			return custom.fn(v, param)
+		}
+	}
+	return r, nil
+}
+
+// sizeRule строит min, max или len: для чисел сравнивается значение, для
+// строк и коллекций — длина.
+func sizeRule(t reflect.Type, r rule) (rule, error) {
+	limit, err := strconv.ParseFloat(r.param, 64)
+	if err != nil {
+		return r, fmt.Errorf("%s=%q: not a number", r.name, r.param)
+	}
+	var measure func(v reflect.Value) float64
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		measure = func(v reflect.Value) float64 { return float64(v.Int()) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		measure = func(v reflect.Value) float64 { return float64(v.Uint()) }
+	case reflect.Float32, reflect.Float64:
+		measure = func(v reflect.Value) float64 { return v.Float() }
+	case reflect.String:
+		measure = func(v reflect.Value) float64 { return float64(utf8.RuneCountInString(v.String())) }
+	case reflect.Slice, reflect.Array, reflect.Map:
+		measure = func(v reflect.Value) float64 { return float64(v.Len()) }
+	default:
+		return r, fmt.Errorf("%s on %s", r.name, t)
+	}
+
+	switch r.name {
+	case "min":
+		r.check = func(v reflect.Value) bool { return measure(v) >= limit }
+	case "max":
+		r.check = func(v reflect.Value) bool { return measure(v) <= limit }
+	default:
+		r.check = func(v reflect.Value) bool { return measure(v) == limit }
+	}
+	return r, nil
+}
+
+func (val *Validator) validateStruct(v reflect.Value, prefix string, errs *[]interfaces.ValidationError) {
+	for _, f := range val.spec(v.Type()).fields {
+		fv := v.Field(f.index)
+		if f.inline {
+			if fv = deref(fv); fv.IsValid() {
+				val.validateStruct(fv, prefix, errs)
+			}
+			continue
+		}
+		val.validateValue(fv, prefix+f.name, &f.chain, errs)
+	}
+}
+
+func (val *Validator) validateValue(v reflect.Value, path string, c *chain, errs *[]interfaces.ValidationError) {
+	v = deref(v)
+	if !v.IsValid() {
+		// nil-указатель или интерфейс: поле не передано.
+		if c.required {
+			*errs = append(*errs, interfaces.ValidationError{Field: path, Rule: "required"})
+		}
+		return
+	}
+	if c.omitempty && !isPresent(v) {
+		return
+	}
+	for _, r := range c.rules {
+		if !r.check(v) {
+			*errs = append(*errs, interfaces.ValidationError{
+				Field: path,
+				Value: v.Interface(),
+				Msg:   r.msg,
+				Rule:  r.name,
+				Param: r.param,
+			})
+			// Первое нарушенное правило поля — остальные обычно следуют из
+			// него и только загромождают ответ.
+			return
+		}
+	}
+
+	switch {
+	case c.dive != nil && v.Kind() == reflect.Map:
+		// Ключи сортируются, чтобы порядок ошибок не менялся от вызова к вызову.
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		for _, key := range keys {
+			val.validateValue(v.MapIndex(key), fmt.Sprintf("%s[%v]", path, key), c.dive, errs)
+		}
+	case c.dive != nil:
+		for i := 0; i < v.Len(); i++ {
+			val.validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), c.dive, errs)
+		}
+	case v.Kind() == reflect.Struct:
+		val.validateStruct(v, path+".", errs)
+	}
+}
+
+// isPresent — правило required: nil, пустая строка, слайс или map и нулевое
+// значение считаются отсутствующими. Длина массива задана типом, поэтому
+// массив проверяется на нулевое значение, как число.
+func isPresent(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() > 0
+	case reflect.Ptr, reflect.Interface:
+		return !v.IsNil()
+	default:
+		return !v.IsZero()
+	}
+}
+
+func deref(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,193 @@
+package reflection
+
+import (
+	"reflect"
+	"testing"
+
+	"go-showcase/interfaces"
+)
+
+// violations сводит ошибки к "поле:правило=параметр" для сравнения.
+func violations(errs []interfaces.ValidationError) []string {
+	out := []string{}
+	for _, e := range errs {
+		v := e.Field + ":" + e.Rule
+		if e.Param != "" {
+			v += "=" + e.Param
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func TestValidateRules(t *testing.T) {
+	type required struct {
+		Name  string            `json:"name" validate:"required"`
+		Age   int               `json:"age" validate:"required"`
+		Tags  []string          `json:"tags" validate:"required"`
+		Meta  map[string]string `json:"meta" validate:"required"`
+		Ptr   *int              `json:"ptr" validate:"required"`
+		Code  [2]byte           `json:"code" validate:"required"`
+		Other string            `json:"other"`
+	}
+	type numbers struct {
+		Int   int     `json:"int" validate:"min=1,max=10"`
+		Uint  uint8   `json:"uint" validate:"max=5"`
+		Float float64 `json:"float" validate:"min=0.5"`
+		Exact int     `json:"exact" validate:"len=3"`
+	}
+	type lengths struct {
+		Name  string         `json:"name" validate:"min=2,max=4"`
+		Pin   string         `json:"pin" validate:"len=4"`
+		Tags  []string       `json:"tags" validate:"min=1,max=2"`
+		Pair  []int          `json:"pair" validate:"len=2"`
+		Meta  map[string]int `json:"meta" validate:"max=1"`
+		Array [3]int         `json:"array" validate:"len=3"`
+	}
+	type formats struct {
+		Email string `json:"email" validate:"omitempty,email"`
+		Role  string `json:"role" validate:"oneof=admin user"`
+		Level int    `json:"level" validate:"oneof=1 2 3"`
+		Code  string `json:"code" validate:"regex=^[A-Z]{2},[0-9]+$"`
+	}
+	one := 1
+
+	tests := []struct {
+		name string
+		src  interface{}
+		want []string
+	}{
+		{"required missing", required{},
+			[]string{"name:required", "age:required", "tags:required", "meta:required", "ptr:required", "code:required"}},
+		{"required present", required{
+			Name: "a", Age: 1, Tags: []string{""}, Meta: map[string]string{"": ""}, Ptr: &one, Code: [2]byte{0, 1},
+		}, []string{}},
+
+		{"numbers valid", numbers{Int: 10, Uint: 5, Float: 0.5, Exact: 3}, []string{}},
+		{"numbers below", numbers{Int: 0, Float: 0.4, Exact: 3}, []string{"int:min=1", "float:min=0.5"}},
+		{"numbers above", numbers{Int: 11, Uint: 6, Float: 1, Exact: 4}, []string{"int:max=10", "uint:max=5", "exact:len=3"}},
+
+		// Длина строки — в символах, а не в байтах.
+		{"lengths valid", lengths{Name: "Юля", Pin: "1234", Tags: []string{"a"}, Pair: []int{1, 2}},
+			[]string{}},
+		{"lengths below", lengths{Name: "Ю", Pin: "123", Pair: []int{1}},
+			[]string{"name:min=2", "pin:len=4", "tags:min=1", "pair:len=2"}},
+		{"lengths above", lengths{Name: "Юлиан", Pin: "12345", Tags: []string{"a", "b", "c"}, Pair: []int{1, 2, 3}, Meta: map[string]int{"a": 1, "b": 2}},
+			[]string{"name:max=4", "pin:len=4", "tags:max=2", "pair:len=2", "meta:max=1"}},
+
+		{"formats valid", formats{Email: "Ann@Example.com", Role: "user", Level: 2, Code: "AB,12"}, []string{}},
+		{"formats invalid", formats{Email: "ann@", Role: "root", Level: 4, Code: "ab,12"},
+			[]string{"email:email", "role:oneof=admin user", "level:oneof=1 2 3", "code:regex=^[A-Z]{2},[0-9]+$"}},
+		{"omitempty skips zero", formats{Role: "admin", Level: 1, Code: "AB,1"}, []string{}},
+
+		{"pointer to struct", &numbers{Int: 0, Float: 1, Exact: 3}, []string{"int:min=1"}},
+		{"nil pointer", (*numbers)(nil), nil},
+		{"pointer field", struct {
+			Age *int `json:"age" validate:"min=2"`
+		}{Age: &one}, []string{"age:min=2"}},
+		{"nil pointer field", struct {
+			Age *int `json:"age" validate:"min=2"`
+		}{}, []string{}},
+	}
+	for _, tt := range tests {
+		got := violations(Validate(tt.src))
+		if tt.want == nil {
+			if errs := Validate(tt.src); errs != nil {
+				t.Errorf("%s: got %v, want nil", tt.name, got)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateNested(t *testing.T) {
+	type address struct {
+		City string `json:"city" validate:"required"`
+	}
+	type Base struct {
+		ID int `json:"id" validate:"min=1"`
+	}
+	type user struct {
+		Base
+		Home   address            `json:"home"`
+		Work   *address           `json:"work"`
+		Emails []string           `json:"emails" validate:"max=2,dive,email"`
+		Prev   []address          `json:"prev" validate:"dive"`
+		Limits map[string]int     `json:"limits" validate:"dive,max=5"`
+		Notes  map[string]address `json:"notes"`
+	}
+
+	u := user{
+		Work:   &address{},
+		Emails: []string{"a@example.com", "bad"},
+		Prev:   []address{{City: "Riga"}, {}},
+		Limits: map[string]int{"b": 6, "a": 7, "c": 1},
+		// Структуры в коллекциях без dive не проверяются.
+		Notes: map[string]address{"x": {}},
+	}
+	want := []string{
+		"id:min=1", "home.city:required", "work.city:required", "emails[1]:email",
+		"prev[1].city:required", "limits[a]:max=5", "limits[b]:max=5",
+	}
+	if got := violations(Validate(u)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// TestValidateErrorFields: встроенные правила отдают код и параметр без
+// текста, своё правило — текст из RegisterRule.
+func TestValidateErrorFields(t *testing.T) {
+	val := NewValidator()
+	val.RegisterRule("even", func(v reflect.Value, _ string) bool { return v.Int()%2 == 0 }, "must be even")
+	type input struct {
+		Name  string `json:"name" validate:"max=3"`
+		Count int    `json:"count" validate:"even"`
+		Email string `json:"email" validate:"required"`
+	}
+
+	got := val.Validate(input{Name: "abcd", Count: 3})
+	want := []interfaces.ValidationError{
+		{Field: "name", Value: "abcd", Rule: "max", Param: "3"},
+		{Field: "count", Value: 3, Rule: "even", Msg: "must be even"},
+		{Field: "email", Value: "", Rule: "required"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestValidateInvalidTag(t *testing.T) {
+	tests := []struct {
+		name string
+		src  interface{}
+	}{
+		{"unknown rule", struct {
+			A string `validate:"nope"`
+		}{}},
+		{"min without number", struct {
+			A string `validate:"min=x"`
+		}{}},
+		{"email on int", struct {
+			A int `validate:"email"`
+		}{}},
+		{"dive on string", struct {
+			A string `validate:"dive,required"`
+		}{}},
+		{"empty oneof", struct {
+			A string `validate:"oneof="`
+		}{}},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: Validate did not panic", tt.name)
+				}
+			}()
+			Validate(tt.src)
+		}()
+	}
+}
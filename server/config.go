@@ -0,0 +1,429 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"go-showcase/middleware"
+	"go-showcase/tracing"
+	ws "go-showcase/websocket"
+)
+
+type Config struct {
+	Addr      string
+	Log       middleware.LogConfig
+	WebSocket ws.GuardConfig
+	Hub       ws.HubConfig
+	// BrokerURL — "memory" или "redis://host:port/channel"; пусто — без брокера.
+	BrokerURL string
+	// DatabasePath — файл SQLite с пользователями; пусто — пользователи
+	// хранятся в памяти и пропадают при перезапуске.
+	DatabasePath string
+	// DatabaseMigrate — применять недостающие миграции при запуске; если
+	// выключено, сервер с устаревшей схемой не запустится.
+	DatabaseMigrate bool
+
+	Tracing tracing.Config
+	// TracingExporter — "memory" или адрес OTLP/HTTP коллектора; пусто —
+	// трасса передаётся дальше, но спаны не отправляются.
+	TracingExporter string
+	// TracingHeaders добавляются к запросам в коллектор.
+	TracingHeaders map[string]string
+
+	// ErrorReporter — куда отправлять отчёты о паниках: путь к файлу
+	// (JSON Lines) или адрес HTTP-коллектора; пусто — только лог.
+	ErrorReporter string
+
+	Auth        middleware.Authenticator
+	CORS        middleware.CORSConfig
+	Security    middleware.SecurityHeadersConfig
+	Timeout     middleware.TimeoutConfig
+	BodyLimit   middleware.BodyLimitConfig
+	Cache       middleware.CacheConfig
+	Idempotency middleware.IdempotencyConfig
+	RateLimit   RateLimitConfig
+	// TrustedProxies — адреса и подсети прокси, чьим X-Forwarded-For и
+	// Forwarded можно верить.
+	TrustedProxies []string
+}
+
+type RateLimitConfig struct {
+	// Store — "memory" или URL сервиса лимитов другой реплики.
+	Store string
+	// Token защищает сервис лимитов; его же HTTPStore передаёт в запросах.
+	Token string
+	// ServeAddr — если задан, на этом адресе отдаётся сервис лимитов.
+	ServeAddr string
+	Limit     middleware.Limit
+	// Tiers — лимиты по тарифам пользователей для маршрутов без своей политики.
+	Tiers map[string]middleware.Limit
+	// Routes — политики по шаблонам маршрутов ("/api/health", "POST /api/users").
+	Routes map[string]middleware.RateLimitPolicy
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Addr:      ":8080",
+		Log:       middleware.DefaultLogConfig(),
+		Tracing:   tracing.DefaultConfig(),
+		WebSocket: ws.DefaultGuardConfig(),
+		Hub:       ws.DefaultHubConfig(),
+		CORS:      defaultCORSConfig(),
+		Security:  middleware.DefaultSecurityHeadersConfig(),
+		Timeout: middleware.TimeoutConfig{
+			Default: 10 * time.Second,
+			Routes: map[string]time.Duration{
+				"/api/users/export": 30 * time.Second,
+				// WebSocket живёт дольше любого таймаута и требует Hijack.
+				"/ws": 0,
+			},
+		},
+		BodyLimit: middleware.BodyLimitConfig{
+			Default: 1 << 20,
+			Routes: map[string]int64{
+				"POST /api/users":         16 << 10,
+				"PUT /api/users/{id}":     16 << 10,
+				"DELETE /api/users/batch": 64 << 10,
+			},
+		},
+		Cache:       defaultCacheConfig(),
+		Idempotency: middleware.DefaultIdempotencyConfig(),
+		RateLimit: RateLimitConfig{
+			Store: "memory",
+			Limit: middleware.Limit{
+				Algorithm: middleware.TokenBucket,
+				Rate:      rate.Limit(10),
+				Burst:     20,
+			},
+			Tiers: map[string]middleware.Limit{
+				"pro": {Rate: rate.Limit(50), Burst: 100},
+			},
+			Routes: map[string]middleware.RateLimitPolicy{
+				"/":           {Exempt: true},
+				"/api/health": {Exempt: true},
+				// Тяжёлые операции: пакетные изменения и выгрузка.
+				"POST /api/users/batch": {
+					Name:  "batch",
+					Limit: middleware.Limit{Rate: rate.Limit(1), Burst: 5},
+					Tiers: map[string]middleware.Limit{"pro": {Rate: rate.Limit(5), Burst: 20}},
+				},
+				"DELETE /api/users/batch": {
+					Name:  "batch",
+					Limit: middleware.Limit{Rate: rate.Limit(1), Burst: 5},
+					Tiers: map[string]middleware.Limit{"pro": {Rate: rate.Limit(5), Burst: 20}},
+				},
+				"/api/users/export": {
+					Limit: middleware.Limit{Rate: rate.Limit(0.2), Burst: 3},
+					Tiers: map[string]middleware.Limit{"pro": {Rate: rate.Limit(1), Burst: 10}},
+				},
+			},
+		},
+		DatabaseMigrate: true,
+	}
+}
+
+// LoadConfig берёт DefaultConfig и переопределяет значения из переменных
+// окружения. Некорректные значения логируются и игнорируются.
+func LoadConfig() Config {
+	cfg := DefaultConfig()
+
+	cfg.Addr = envString("ADDR", cfg.Addr)
+	cfg.BrokerURL = envString("BROKER_URL", cfg.BrokerURL)
+	cfg.DatabasePath = envString("DATABASE_PATH", cfg.DatabasePath)
+	cfg.DatabaseMigrate = envBool("DATABASE_MIGRATE", cfg.DatabaseMigrate)
+	cfg.TrustedProxies = envList("TRUSTED_PROXIES", cfg.TrustedProxies)
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := cfg.Log.Level.UnmarshalText([]byte(v)); err != nil {
+			log.Printf("LOG_LEVEL: %v", err)
+		}
+	}
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		format, err := middleware.ParseLogFormat(v)
+		if err != nil {
+			log.Printf("LOG_FORMAT: %v", err)
+		} else {
+			cfg.Log.Format = format
+		}
+	}
+
+	cfg.TracingExporter = envString("TRACING_EXPORTER", cfg.TracingExporter)
+	cfg.Tracing.ServiceName = envString("TRACING_SERVICE_NAME", cfg.Tracing.ServiceName)
+	cfg.Tracing.SampleRatio = envFloat("TRACING_SAMPLE_RATIO", cfg.Tracing.SampleRatio)
+	if v := os.Getenv("TRACING_HEADERS"); v != "" {
+		headers, err := parseHeaders(v)
+		if err != nil {
+			log.Printf("TRACING_HEADERS: %v", err)
+		} else {
+			cfg.TracingHeaders = headers
+		}
+	}
+
+	cfg.ErrorReporter = envString("ERROR_REPORTER", cfg.ErrorReporter)
+
+	cfg.CORS.AllowedOrigins = envList("CORS_ALLOWED_ORIGINS", cfg.CORS.AllowedOrigins)
+	cfg.CORS.AllowCredentials = envBool("CORS_ALLOW_CREDENTIALS", cfg.CORS.AllowCredentials)
+	cfg.CORS.MaxAge = envDuration("CORS_MAX_AGE", cfg.CORS.MaxAge)
+
+	cfg.Security.ContentSecurityPolicy = envString("CSP", cfg.Security.ContentSecurityPolicy)
+	cfg.Security.CSPReportOnly = envBool("CSP_REPORT_ONLY", cfg.Security.CSPReportOnly)
+	cfg.Security.HSTSMaxAge = envDuration("HSTS_MAX_AGE", cfg.Security.HSTSMaxAge)
+	cfg.Security.HSTSPreload = envBool("HSTS_PRELOAD", cfg.Security.HSTSPreload)
+
+	cfg.Timeout.Default = envDuration("REQUEST_TIMEOUT", cfg.Timeout.Default)
+	if v := os.Getenv("REQUEST_TIMEOUT_ROUTES"); v != "" {
+		routes, err := parseTimeouts(v)
+		if err != nil {
+			log.Printf("REQUEST_TIMEOUT_ROUTES: %v", err)
+		} else {
+			for route, d := range routes {
+				cfg.Timeout.Routes[route] = d
+			}
+		}
+	}
+
+	cfg.BodyLimit.Default = int64(envInt("MAX_BODY_SIZE", int(cfg.BodyLimit.Default)))
+
+	cfg.Cache.MaxEntries = envInt("CACHE_MAX_ENTRIES", cfg.Cache.MaxEntries)
+	cfg.Cache.TTL = envDuration("CACHE_TTL", cfg.Cache.TTL)
+
+	cfg.Idempotency.TTL = envDuration("IDEMPOTENCY_TTL", cfg.Idempotency.TTL)
+	cfg.Idempotency.Wait = envDuration("IDEMPOTENCY_WAIT", cfg.Idempotency.Wait)
+
+	cfg.RateLimit.Store = envString("RATE_LIMIT_STORE", cfg.RateLimit.Store)
+	cfg.RateLimit.Token = envString("RATE_LIMIT_TOKEN", cfg.RateLimit.Token)
+	cfg.RateLimit.ServeAddr = envString("RATE_LIMIT_SERVE_ADDR", cfg.RateLimit.ServeAddr)
+	cfg.RateLimit.Limit.Rate = rate.Limit(envFloat("RATE_LIMIT_RATE", float64(cfg.RateLimit.Limit.Rate)))
+	cfg.RateLimit.Limit.Burst = envInt("RATE_LIMIT_BURST", cfg.RateLimit.Limit.Burst)
+	if v := os.Getenv("RATE_LIMIT_TIERS"); v != "" {
+		tiers, err := parseTiers(v)
+		if err != nil {
+			log.Printf("RATE_LIMIT_TIERS: %v", err)
+		} else {
+			cfg.RateLimit.Tiers = tiers
+		}
+	}
+	if v := os.Getenv("RATE_LIMIT_ALGORITHM"); v != "" {
+		algorithm, err := middleware.ParseAlgorithm(v)
+		if err != nil {
+			log.Printf("RATE_LIMIT_ALGORITHM: %v", err)
+		} else {
+			cfg.RateLimit.Limit.Algorithm = algorithm
+		}
+	}
+
+	cfg.WebSocket.AllowedOrigins = envList("WS_ALLOWED_ORIGINS", cfg.WebSocket.AllowedOrigins)
+	cfg.WebSocket.RequireAuth = envBool("WS_REQUIRE_AUTH", cfg.WebSocket.RequireAuth)
+	cfg.WebSocket.MaxConnections = envInt("WS_MAX_CONNECTIONS", cfg.WebSocket.MaxConnections)
+	cfg.WebSocket.MaxConnectionsPerIP = envInt("WS_MAX_CONNECTIONS_PER_IP", cfg.WebSocket.MaxConnectionsPerIP)
+	cfg.WebSocket.MaxMessageSize = int64(envInt("WS_MAX_MESSAGE_SIZE", int(cfg.WebSocket.MaxMessageSize)))
+	cfg.WebSocket.MessageRate = rate.Limit(envFloat("WS_MESSAGE_RATE", float64(cfg.WebSocket.MessageRate)))
+	cfg.WebSocket.MessageBurst = envInt("WS_MESSAGE_BURST", cfg.WebSocket.MessageBurst)
+
+	cfg.Hub.WriteWait = envDuration("WS_WRITE_WAIT", cfg.Hub.WriteWait)
+	cfg.Hub.PongWait = envDuration("WS_PONG_WAIT", cfg.Hub.PongWait)
+	cfg.Hub.PingPeriod = envDuration("WS_PING_PERIOD", cfg.Hub.PingPeriod)
+	cfg.Hub.HeartbeatInterval = envDuration("WS_HEARTBEAT_INTERVAL", cfg.Hub.HeartbeatInterval)
+	cfg.Hub.ReadBufferSize = envInt("WS_READ_BUFFER_SIZE", cfg.Hub.ReadBufferSize)
+	cfg.Hub.WriteBufferSize = envInt("WS_WRITE_BUFFER_SIZE", cfg.Hub.WriteBufferSize)
+	cfg.Hub.SendBufferSize = envInt("WS_SEND_BUFFER_SIZE", cfg.Hub.SendBufferSize)
+	cfg.Hub.BlockTimeout = envDuration("WS_BLOCK_TIMEOUT", cfg.Hub.BlockTimeout)
+	cfg.Hub.MaxInflightRequests = envInt("WS_MAX_INFLIGHT", cfg.Hub.MaxInflightRequests)
+	cfg.Hub.MaxBatchSize = envInt("WS_MAX_BATCH_SIZE", cfg.Hub.MaxBatchSize)
+	cfg.Hub.EnableCompression = envBool("WS_COMPRESSION", cfg.Hub.EnableCompression)
+	cfg.Hub.CompressionThreshold = envInt("WS_COMPRESSION_THRESHOLD", cfg.Hub.CompressionThreshold)
+
+	if v := os.Getenv("WS_BACKPRESSURE"); v != "" {
+		policy, err := ws.ParseBackpressurePolicy(v)
+		if err != nil {
+			log.Printf("WS_BACKPRESSURE: %v", err)
+		} else {
+			cfg.Hub.Backpressure = policy
+		}
+	}
+
+	if spec := os.Getenv("AUTH_TOKENS"); spec != "" {
+		tokens, err := middleware.ParseTokens(spec)
+		if err != nil {
+			log.Printf("AUTH_TOKENS: %v", err)
+		} else {
+			cfg.Auth = tokens
+			cfg.WebSocket.Authenticator = tokens
+		}
+	}
+
+	return cfg
+}
+
+// defaultCacheConfig кэширует чтения пользователей; любая запись в store
+// инвалидирует тег "users" (см. StartServer).
+func defaultCacheConfig() middleware.CacheConfig {
+	cache := middleware.DefaultCacheConfig()
+	users := middleware.CachePolicy{Tags: []string{"users"}}
+	cache.Routes = map[string]middleware.CachePolicy{
+		"/api/users":           users,
+		"/api/users/{id}":      users,
+		"/api/users/search":    users,
+		"/api/users/analytics": users,
+		// Статистика включает счётчики WebSocket и трассировки, которые
+		// меняются без записи в store.
+		"/api/stats": {TTL: 5 * time.Second, Tags: []string{"users"}},
+	}
+	return cache
+}
+
+func defaultCORSConfig() middleware.CORSConfig {
+	cors := middleware.DefaultCORSConfig()
+	cors.Routes = map[string]middleware.CORSConfig{
+		// Имя файла выгрузки браузер увидит только из открытого заголовка.
+		"/api/users/export": {
+			ExposedHeaders: append(append([]string{}, cors.ExposedHeaders...), "Content-Disposition"),
+		},
+	}
+	return cors
+}
+
+// parseTiers разбирает "pro:50:100,free:2:5" — тариф, запросов в секунду
+// и burst.
+func parseTiers(spec string) (map[string]middleware.Limit, error) {
+	tiers := make(map[string]middleware.Limit)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid tier %q: want tier:rate:burst", entry)
+		}
+		r, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tier %q: %v", entry, err)
+		}
+		burst, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid tier %q: %v", entry, err)
+		}
+		tiers[parts[0]] = middleware.Limit{Rate: rate.Limit(r), Burst: burst}
+	}
+	return tiers, nil
+}
+
+// parseTimeouts разбирает "/api/users/export=1m,POST /api/users/batch=20s";
+// 0 отключает таймаут маршрута.
+func parseTimeouts(spec string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, "=")
+		route = strings.TrimSpace(route)
+		if !ok || route == "" {
+			return nil, fmt.Errorf("invalid timeout %q: want route=duration", entry)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %q: %v", entry, err)
+		}
+		timeouts[route] = d
+	}
+	return timeouts, nil
+}
+
+// parseHeaders разбирает "Name=value,Other=value".
+func parseHeaders(spec string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q: want name=value", entry)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+func envString(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func envList(key string, def []string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func envInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("%s: %v", key, err)
+		return def
+	}
+	return n
+}
+
+func envFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("%s: %v", key, err)
+		return def
+	}
+	return f
+}
+
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("%s: %v", key, err)
+		return def
+	}
+	return d
+}
+
+func envBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("%s: %v", key, err)
+		return def
+	}
+	return b
+}
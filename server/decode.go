@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"go-showcase/problem"
+)
+
+// decodeJSON строго разбирает тело запроса в dst: Content-Type должен быть
+// application/json, тело — ровно один JSON-объект без неизвестных полей.
+// При ошибке отвечает клиенту сам и возвращает false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := decodeJSONBody(r, dst); err != nil {
+		problem.Write(w, r, err)
+		return false
+	}
+	return true
+}
+
+func decodeJSONBody(r *http.Request, dst interface{}) *problem.Error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return problem.New(problem.UnsupportedMediaType, "application/json")
+	}
+
+	// Тело уже ограничено middleware.BodyLimit, так что его можно прочитать
+	// целиком: смещения в ошибках тогда считаются от начала тела.
+	body, err := io.ReadAll(r.Body)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return problem.New(problem.PayloadTooLarge, maxErr.Limit)
+	}
+	if err != nil {
+		return problem.New(problem.RequestCanceled)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return jsonDecodeError(err, len(body))
+	}
+	// После объекта допустимы только пробельные символы.
+	end := dec.InputOffset()
+	if _, err := dec.Token(); err != io.EOF {
+		return problem.New(problem.MalformedJSON, end)
+	}
+	return nil
+}
+
+func jsonDecodeError(err error, size int) *problem.Error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return problem.New(problem.EmptyBody)
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return problem.New(problem.MalformedJSON, size)
+
+	case errors.As(err, &syntaxErr):
+		return problem.New(problem.MalformedJSON, syntaxErr.Offset)
+
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return problem.New(problem.MalformedJSON, typeErr.Offset)
+		}
+		return problem.Invalid(problem.Violation{
+			Field: typeErr.Field,
+			Rule:  problem.RuleType,
+			Args:  []interface{}{jsonTypeName(typeErr.Type)},
+		})
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return problem.Invalid(problem.Violation{Field: field, Rule: problem.RuleUnknownField})
+
+	default:
+		return problem.New(problem.MalformedJSON, 0)
+	}
+}
+
+// jsonTypeName называет ожидаемый тип в терминах JSON.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
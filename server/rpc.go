@@ -0,0 +1,182 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go-showcase/middleware"
+	"go-showcase/problem"
+	ws "go-showcase/websocket"
+)
+
+// rpcRoute описывает, каким REST-маршрутом обслуживается RPC-метод.
+// Запрос по WebSocket превращается в обычный *http.Request и проходит через
+// роутер со всеми middleware — лимиты запросов, ограничение тела,
+// идемпотентность, кэш, — поэтому для него действуют те же правила, что и
+// для REST API, а валидация и формат ответа совпадают.
+type rpcRoute struct {
+	method string
+	path   string
+	query  bool
+}
+
+var rpcRoutes = map[string]rpcRoute{
+	"users.list":   {method: http.MethodGet, path: "/api/users", query: true},
+	"users.search": {method: http.MethodGet, path: "/api/users/search", query: true},
+	"users.get":    {method: http.MethodGet, path: "/api/users/{id}"},
+	"users.create": {method: http.MethodPost, path: "/api/users"},
+	"users.update": {method: http.MethodPut, path: "/api/users/{id}"},
+}
+
+func registerRPCMethods(h *ws.Hub, router http.Handler) {
+	for name, route := range rpcRoutes {
+		route := route
+		h.HandleRPC(name, func(ctx context.Context, client *ws.Client, params json.RawMessage) (interface{}, *ws.RPCError) {
+			return callRoute(ctx, router, client, route, params)
+		})
+	}
+}
+
+func callRoute(ctx context.Context, router http.Handler, client *ws.Client, route rpcRoute, params json.RawMessage) (interface{}, *ws.RPCError) {
+	fields := map[string]json.RawMessage{}
+	if len(params) > 0 && string(params) != "null" {
+		if err := json.Unmarshal(params, &fields); err != nil {
+			return nil, &ws.RPCError{
+				Code:    "invalid_params",
+				Message: "params must be a JSON object",
+				Status:  http.StatusBadRequest,
+			}
+		}
+	}
+
+	target := route.path
+	body := []byte(params)
+
+	if strings.Contains(route.path, "{id}") {
+		raw, ok := fields["id"]
+		if !ok {
+			return nil, &ws.RPCError{
+				Code:    "invalid_params",
+				Message: "params.id is required",
+				Status:  http.StatusBadRequest,
+			}
+		}
+		target = strings.Replace(target, "{id}", url.PathEscape(rawParamString(raw)), 1)
+		delete(fields, "id")
+		body, _ = json.Marshal(fields)
+	}
+
+	if route.query {
+		query := url.Values{}
+		for key, raw := range fields {
+			query.Set(key, rawParamString(raw))
+		}
+		target += "?" + query.Encode()
+		body = nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, route.method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, &ws.RPCError{
+			Code:    "internal_error",
+			Message: err.Error(),
+			Status:  http.StatusInternalServerError,
+		}
+	}
+	// Запрос идёт от имени соединения: пользователь — из токена, предъявленного
+	// при апгрейде (Auth без заголовка его не трогает), адрес — клиента,
+	// язык — выбранный при подключении.
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", client.Language())
+	if client.IP != "" {
+		req.RemoteAddr = net.JoinHostPort(client.IP, "0")
+	}
+	if client.Principal != nil {
+		req = req.WithContext(middleware.WithPrincipal(req.Context(), client.Principal))
+	}
+
+	rec := newRPCRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.status >= http.StatusBadRequest {
+		// Ошибки обработчиков приходят в формате problem+json: код и
+		// нарушения по полям переносятся в RPCError как есть.
+		var p problem.Problem
+		json.Unmarshal(rec.body.Bytes(), &p)
+		rpcErr := &ws.RPCError{
+			Code:    string(p.Code),
+			Message: p.Detail,
+			Status:  rec.status,
+		}
+		if rpcErr.Code == "" {
+			rpcErr.Code = rpcCodeForStatus(rec.status)
+		}
+		if rpcErr.Message == "" {
+			rpcErr.Message = p.Title
+		}
+		if rpcErr.Message == "" {
+			rpcErr.Message = http.StatusText(rec.status)
+		}
+		if len(p.Errors) > 0 {
+			rpcErr.Details = p.Errors
+		}
+		return nil, rpcErr
+	}
+
+	return json.RawMessage(bytes.TrimSpace(rec.body.Bytes())), nil
+}
+
+func rawParamString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func rpcCodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_params"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusTooManyRequests:
+		return "too_many_requests"
+	default:
+		return "internal_error"
+	}
+}
+
+type rpcRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRPCRecorder() *rpcRecorder {
+	return &rpcRecorder{header: make(http.Header)}
+}
+
+func (r *rpcRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *rpcRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *rpcRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
@@ -0,0 +1,403 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-showcase/database"
+	"go-showcase/tracing"
+)
+
+var errUserNotFound = errors.New("user not found")
+
+// Store — хранилище пользователей: в памяти или в SQLite (DATABASE_PATH).
+// Каждая операция получает контекст запроса и записывается в его трассу
+// отдельным спаном; если контекст уже отменён (клиент ушёл или истёк
+// таймаут маршрута), операция не выполняется и возвращает ctx.Err().
+type Store struct {
+	mu        sync.RWMutex
+	users     userBackend
+	stats     Stats
+	listeners []func(StoreEvent)
+}
+
+// userBackend хранит пользователей. Store вызывает его под своей
+// блокировкой, так что реализации не обязаны быть потокобезопасными.
+type userBackend interface {
+	// system — атрибут db.system спанов.
+	system() string
+	list(ctx context.Context) ([]User, error)
+	// page возвращает страницу пользователей по opts и общее их число.
+	page(ctx context.Context, opts database.ListOptions) ([]User, int, error)
+	// get возвращает errUserNotFound для отсутствующего пользователя.
+	get(ctx context.Context, id int) (User, error)
+	// create присваивает пользователям ID.
+	create(ctx context.Context, users []User) ([]User, error)
+	// save перезаписывает существующего пользователя.
+	save(ctx context.Context, user User) error
+	// delete удаляет существующих пользователей и возвращает их ID.
+	delete(ctx context.Context, ids []int) ([]int, error)
+	Close() error
+}
+
+// memoryUsers — пользователи в памяти процесса; пропадают при перезапуске.
+type memoryUsers struct {
+	users  map[int]User
+	nextID int
+}
+
+func newMemoryUsers() *memoryUsers {
+	return &memoryUsers{users: make(map[int]User), nextID: 1}
+}
+
+func (m *memoryUsers) system() string { return "memory" }
+
+func (m *memoryUsers) list(ctx context.Context) ([]User, error) {
+	users := make([]User, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// userOrder сравнивает пользователей по столбцу; для сортировки в памяти.
+var userOrder = map[string]func(a, b User) int{
+	"id":         func(a, b User) int { return a.ID - b.ID },
+	"name":       func(a, b User) int { return strings.Compare(a.Name, b.Name) },
+	"email":      func(a, b User) int { return strings.Compare(a.Email, b.Email) },
+	"age":        func(a, b User) int { return a.Age - b.Age },
+	"country":    func(a, b User) int { return strings.Compare(a.Country, b.Country) },
+	"created_at": func(a, b User) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"updated_at": func(a, b User) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+}
+
+// page сортирует и режет всех пользователей в памяти; условия Where —
+// SQL, поэтому здесь не поддерживаются.
+func (m *memoryUsers) page(ctx context.Context, opts database.ListOptions) ([]User, int, error) {
+	if len(opts.Where) > 0 {
+		return nil, 0, errors.New("memory store: filters are not supported")
+	}
+	compare := make([]func(a, b User) int, len(opts.OrderBy))
+	for i, o := range opts.OrderBy {
+		cmp, ok := userOrder[o.Column]
+		if !ok {
+			return nil, 0, fmt.Errorf("memory store: cannot sort users by unknown column %q", o.Column)
+		}
+		if o.Desc {
+			compare[i] = func(a, b User) int { return cmp(b, a) }
+		} else {
+			compare[i] = cmp
+		}
+	}
+
+	users, _ := m.list(ctx)
+	sort.Slice(users, func(i, j int) bool {
+		for _, cmp := range compare {
+			if c := cmp(users[i], users[j]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	total := len(users)
+	start := min(max(opts.Offset, 0), total)
+	end := total
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, total)
+	}
+	return users[start:end], total, nil
+}
+
+func (m *memoryUsers) get(ctx context.Context, id int) (User, error) {
+	user, ok := m.users[id]
+	if !ok {
+		return User{}, errUserNotFound
+	}
+	return user, nil
+}
+
+func (m *memoryUsers) create(ctx context.Context, users []User) ([]User, error) {
+	created := make([]User, 0, len(users))
+	for _, user := range users {
+		user.ID = m.nextID
+		m.users[user.ID] = user
+		m.nextID++
+		created = append(created, user)
+	}
+	return created, nil
+}
+
+func (m *memoryUsers) save(ctx context.Context, user User) error {
+	m.users[user.ID] = user
+	return nil
+}
+
+func (m *memoryUsers) delete(ctx context.Context, ids []int) ([]int, error) {
+	var deleted []int
+	for _, id := range ids {
+		if _, exists := m.users[id]; exists {
+			delete(m.users, id)
+			deleted = append(deleted, id)
+		}
+	}
+	return deleted, nil
+}
+
+func (m *memoryUsers) Close() error { return nil }
+
+// StoreEvent сообщает об изменении пользователей.
+type StoreEvent struct {
+	// Op — "create", "update" или "delete".
+	Op  string
+	IDs []int
+}
+
+// OnChange подписывает fn на изменения. fn вызывается под блокировкой
+// хранилища, сразу после записи и до того, как её увидит кто-то ещё:
+// она должна быть быстрой и не обращаться к Store.
+func (s *Store) OnChange(fn func(StoreEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *Store) notify(op string, ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+	for _, fn := range s.listeners {
+		fn(StoreEvent{Op: op, IDs: ids})
+	}
+}
+
+var store = &Store{
+	users: newMemoryUsers(),
+	stats: Stats{
+		StartTime:      time.Now(),
+		RequestsByPath: make(map[string]int),
+		UsersByCountry: make(map[string]int),
+	},
+}
+
+func (s *Store) startSpan(ctx context.Context, operation string) (context.Context, *tracing.Span) {
+	ctx, span := tracing.Start(ctx, "store.users."+operation)
+	span.SetAttribute("db.system", s.users.system())
+	span.SetAttribute("db.operation", operation)
+	return ctx, span
+}
+
+// ctxErr отмечает в спане, что операция не выполнялась из-за отмены.
+func ctxErr(ctx context.Context, span *tracing.Span) error {
+	err := ctx.Err()
+	if err != nil {
+		span.RecordError(err)
+	}
+	return err
+}
+
+// List возвращает копию всех пользователей в произвольном порядке.
+func (s *Store) List(ctx context.Context) ([]User, error) {
+	ctx, span := s.startSpan(ctx, "list")
+	defer span.End()
+	if err := ctxErr(ctx, span); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users, err := s.users.list(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttribute("db.rows", len(users))
+	return users, nil
+}
+
+// Page возвращает страницу пользователей по фильтрам, сортировке и
+// смещению opts и число всех подходящих пользователей. SQLite выполняет
+// их запросом, а не читает таблицу целиком.
+func (s *Store) Page(ctx context.Context, opts database.ListOptions) ([]User, int, error) {
+	ctx, span := s.startSpan(ctx, "page")
+	defer span.End()
+	if err := ctxErr(ctx, span); err != nil {
+		return nil, 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users, total, err := s.users.page(ctx, opts)
+	if err != nil {
+		span.RecordError(err)
+		return nil, 0, err
+	}
+	span.SetAttribute("db.rows", len(users))
+	return users, total, nil
+}
+
+// Get возвращает пользователя или errUserNotFound.
+func (s *Store) Get(ctx context.Context, id int) (User, error) {
+	ctx, span := s.startSpan(ctx, "get")
+	defer span.End()
+	span.SetAttribute("user.id", id)
+	if err := ctxErr(ctx, span); err != nil {
+		return User{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.users.get(ctx, id)
+}
+
+// Create присваивает пользователю ID и сохраняет его.
+func (s *Store) Create(ctx context.Context, user User) (User, error) {
+	created, err := s.CreateMany(ctx, []User{user})
+	if err != nil {
+		return User{}, err
+	}
+	return created[0], nil
+}
+
+func (s *Store) CreateMany(ctx context.Context, users []User) ([]User, error) {
+	ctx, span := s.startSpan(ctx, "create")
+	defer span.End()
+	span.SetAttribute("db.rows", len(users))
+	if err := ctxErr(ctx, span); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	created, err := s.users.create(ctx, users)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	ids := make([]int, 0, len(created))
+	for _, user := range created {
+		ids = append(ids, user.ID)
+	}
+	s.notify("create", ids)
+	return created, nil
+}
+
+// Update применяет fn к копии пользователя и сохраняет её, если fn не
+// вернула ошибку. Для отсутствующего пользователя — errUserNotFound.
+func (s *Store) Update(ctx context.Context, id int, fn func(user *User) error) (User, error) {
+	ctx, span := s.startSpan(ctx, "update")
+	defer span.End()
+	span.SetAttribute("user.id", id)
+	if err := ctxErr(ctx, span); err != nil {
+		return User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.users.get(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	if err := fn(&user); err != nil {
+		return User{}, err
+	}
+	user.UpdatedAt = time.Now()
+	if err := s.users.save(ctx, user); err != nil {
+		span.RecordError(err)
+		return User{}, err
+	}
+	s.notify("update", []int{id})
+	return user, nil
+}
+
+// Delete удаляет пользователя; для отсутствующего — errUserNotFound.
+func (s *Store) Delete(ctx context.Context, id int) error {
+	deleted, err := s.DeleteMany(ctx, []int{id})
+	if err != nil {
+		return err
+	}
+	if len(deleted) == 0 {
+		return errUserNotFound
+	}
+	return nil
+}
+
+// DeleteMany удаляет существующих пользователей и возвращает их ID.
+func (s *Store) DeleteMany(ctx context.Context, ids []int) ([]int, error) {
+	ctx, span := s.startSpan(ctx, "delete")
+	defer span.End()
+	if err := ctxErr(ctx, span); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted, err := s.users.delete(ctx, ids)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttribute("db.rows", len(deleted))
+	s.notify("delete", deleted)
+	return deleted, nil
+}
+
+// Stats считает статистику по текущим пользователям.
+func (s *Store) Stats(ctx context.Context) (Stats, error) {
+	ctx, span := s.startSpan(ctx, "stats")
+	defer span.End()
+	if err := ctxErr(ctx, span); err != nil {
+		return Stats{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := s.stats
+	stats.RequestsByPath = make(map[string]int, len(s.stats.RequestsByPath))
+	for path, n := range s.stats.RequestsByPath {
+		stats.RequestsByPath[path] = n
+	}
+	stats.Uptime = time.Since(stats.StartTime).Round(time.Second).String()
+	users, err := s.users.list(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return Stats{}, err
+	}
+	stats.TotalUsers = len(users)
+	stats.UsersByCountry = make(map[string]int)
+	for _, user := range users {
+		if user.Active {
+			stats.ActiveUsers++
+		}
+		if user.Country != "" {
+			stats.UsersByCountry[user.Country]++
+		}
+	}
+	return stats, nil
+}
+
+// useBackend переключает хранилище на backend; вызывается при запуске,
+// до первых запросов.
+func (s *Store) useBackend(users userBackend) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = users
+}
+
+// Close освобождает хранилище (закрывает базу данных).
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.users.Close()
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/mattn/go-sqlite3"
+
+	"go-showcase/database"
+	"go-showcase/problem"
+)
+
+// sqlUsers хранит пользователей в таблице users базы SQLite через
+// database.Repository.
+type sqlUsers struct {
+	db    *sql.DB
+	users *database.Repository[User]
+}
+
+// openSQLUsers открывает базу и приводит её схему к последней версии. Если
+// migrate выключен, база с неприменёнными миграциями не открывается: их
+// применяет "go-showcase migrate up".
+func openSQLUsers(ctx context.Context, path string, migrate bool) (*sqlUsers, error) {
+	db, err := database.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrateSchema(ctx, db, migrate); err != nil {
+		db.Close()
+		return nil, err
+	}
+	users, err := database.NewRepository[User](db, "users")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlUsers{db: db, users: users}, nil
+}
+
+func migrateSchema(ctx context.Context, db *sql.DB, migrate bool) error {
+	migrator := database.NewMigrator(db, database.Migrations)
+	if !migrate {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migrations, first %s; run \"migrate up\"", len(pending), pending[0])
+		}
+		return nil
+	}
+	applied, err := migrator.Up(ctx)
+	for _, m := range applied {
+		slog.Info("migration applied", "migration", m.String())
+	}
+	return err
+}
+
+func (s *sqlUsers) system() string { return "sqlite" }
+
+func (s *sqlUsers) list(ctx context.Context) ([]User, error) {
+	return s.users.List(ctx, database.ListOptions{OrderBy: []database.Order{database.Asc("id")}})
+}
+
+// page выполняет выборку страницы и подсчёт строк в базе. Хранилище
+// держит блокировку на чтение, так что между двумя запросами таблица не
+// меняется.
+func (s *sqlUsers) page(ctx context.Context, opts database.ListOptions) ([]User, int, error) {
+	users, err := s.users.List(ctx, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := s.users.Count(ctx, opts.Where...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (s *sqlUsers) get(ctx context.Context, id int) (User, error) {
+	user, err := s.users.Get(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		return User{}, errUserNotFound
+	}
+	return user, err
+}
+
+// create добавляет пользователей в одной транзакции: если один из них не
+// сохранился, не сохраняется ни один.
+func (s *sqlUsers) create(ctx context.Context, users []User) ([]User, error) {
+	var created []User
+	err := database.WithTx(ctx, s.db, nil, func(ctx context.Context, tx database.Querier) error {
+		repo := s.users.With(tx)
+		created = make([]User, 0, len(users))
+		for _, user := range users {
+			user.ID = 0
+			if err := repo.Create(ctx, &user); err != nil {
+				return uniqueEmail(err, user)
+			}
+			created = append(created, user)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (s *sqlUsers) save(ctx context.Context, user User) error {
+	err := s.users.Update(ctx, &user)
+	if errors.Is(err, database.ErrNotFound) {
+		return errUserNotFound
+	}
+	return uniqueEmail(err, user)
+}
+
+// uniqueEmail превращает нарушение UNIQUE(email) в ответ 409: в памяти
+// дубликаты не проверяются, а таблица их не допускает.
+func uniqueEmail(err error, user User) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return problem.New(problem.EmailTaken, user.Email)
+	}
+	return err
+}
+
+// delete удаляет пользователей в одной транзакции; отсутствующие id
+// пропускаются.
+func (s *sqlUsers) delete(ctx context.Context, ids []int) ([]int, error) {
+	var deleted []int
+	err := database.WithTx(ctx, s.db, nil, func(ctx context.Context, tx database.Querier) error {
+		repo := s.users.With(tx)
+		deleted = nil
+		for _, id := range ids {
+			err := repo.Delete(ctx, id)
+			if errors.Is(err, database.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			deleted = append(deleted, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+func (s *sqlUsers) Close() error {
+	return s.db.Close()
+}
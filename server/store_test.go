@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-showcase/database"
+)
+
+// TestStorePage проверяет, что SQLite и память отдают одинаковые страницы.
+func TestStorePage(t *testing.T) {
+	ctx := context.Background()
+	sqlite, err := openSQLUsers(ctx, filepath.Join(t.TempDir(), "users.db"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backends := map[string]*Store{
+		"memory": {users: newMemoryUsers()},
+		"sqlite": {users: sqlite},
+	}
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var users []User
+	for i := 0; i < 25; i++ {
+		users = append(users, User{
+			Name:      fmt.Sprintf("user-%02d", (i*7)%25),
+			Email:     fmt.Sprintf("user%d@example.com", i),
+			Age:       20 + i%5, // равные значения: порядок решает id
+			Active:    true,
+			CreatedAt: created.Add(time.Duration(i%3) * time.Hour),
+			UpdatedAt: created,
+		})
+	}
+	for name, s := range backends {
+		defer s.Close()
+		if _, err := s.CreateMany(ctx, users); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	options := []database.ListOptions{
+		{OrderBy: []database.Order{database.Asc("id")}, Limit: 10},
+		{OrderBy: []database.Order{database.Asc("name"), database.Asc("id")}, Limit: 10, Offset: 10},
+		{OrderBy: []database.Order{database.Desc("age"), database.Asc("id")}, Limit: 7, Offset: 3},
+		{OrderBy: []database.Order{database.Desc("created_at"), database.Asc("id")}, Limit: 10, Offset: 20},
+		{OrderBy: []database.Order{database.Asc("id")}, Limit: 10, Offset: 30},
+	}
+	for _, opts := range options {
+		var want []int
+		for _, name := range []string{"memory", "sqlite"} {
+			page, total, err := backends[name].Page(ctx, opts)
+			if err != nil {
+				t.Fatalf("%s %+v: %v", name, opts, err)
+			}
+			if total != len(users) {
+				t.Errorf("%s %+v: total = %d, want %d", name, opts, total, len(users))
+			}
+			ids := []int{}
+			for _, user := range page {
+				ids = append(ids, user.ID)
+			}
+			if want == nil {
+				want = ids
+			} else if !reflect.DeepEqual(ids, want) {
+				t.Errorf("%+v: sqlite returned %v, memory %v", opts, ids, want)
+			}
+		}
+		if expected := max(0, min(opts.Limit, len(users)-opts.Offset)); len(want) != expected {
+			t.Errorf("%+v: page has %d users, want %d", opts, len(want), expected)
+		}
+	}
+
+	for name, s := range backends {
+		_, _, err := s.Page(ctx, database.ListOptions{OrderBy: []database.Order{database.Asc("password")}})
+		if err == nil {
+			t.Errorf("%s: sorting by an unknown column succeeded", name)
+		}
+	}
+}
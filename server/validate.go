@@ -0,0 +1,63 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+
+	"go-showcase/interfaces"
+	"go-showcase/problem"
+	"go-showcase/reflection"
+)
+
+// validate проверяет input по тегам validate и переводит нарушения в
+// problem.Violation; nil — если нарушений нет.
+func validate(input interface{}) *problem.Error {
+	errs := reflection.Validate(input)
+	if len(errs) == 0 {
+		return nil
+	}
+	violations := make([]problem.Violation, 0, len(errs))
+	for _, e := range errs {
+		violations = append(violations, violation(e))
+	}
+	return problem.Invalid(violations...)
+}
+
+// violation выбирает код правила для клиента: min, max и len у строк и
+// коллекций ограничивают длину, и сообщение должно говорить об этом.
+func violation(e interfaces.ValidationError) problem.Violation {
+	v := problem.Violation{Field: e.Field, Rule: problem.Rule(e.Rule), Message: e.Msg}
+	if e.Param != "" {
+		v.Args = []interface{}{e.Param}
+	}
+
+	var kind reflect.Kind
+	if e.Value != nil {
+		kind = reflect.TypeOf(e.Value).Kind()
+	}
+	bySize := func(number, length, items problem.Rule) problem.Rule {
+		switch kind {
+		case reflect.String:
+			return length
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return items
+		default:
+			return number
+		}
+	}
+
+	switch e.Rule {
+	case "min":
+		v.Rule = bySize(problem.RuleMin, problem.RuleMinLength, problem.RuleMinItems)
+	case "max":
+		v.Rule = bySize(problem.RuleMax, problem.RuleMaxLength, problem.RuleMaxItems)
+	case "len":
+		v.Rule = bySize(problem.RuleLength, problem.RuleLength, problem.RuleItemCount)
+	case "oneof":
+		v.Args = []interface{}{strings.Join(strings.Fields(e.Param), ", ")}
+	case "regex":
+		// Шаблон — деталь реализации, клиенту он ни к чему.
+		v.Rule, v.Args = problem.RuleFormat, nil
+	}
+	return v
+}
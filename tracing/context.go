@@ -0,0 +1,170 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Заголовки W3C Trace Context: https://www.w3.org/TR/trace-context/
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// FlagSampled — бит trace-flags: вызывающая сторона записывает трассу.
+const FlagSampled byte = 0x01
+
+const (
+	maxTracestateMembers = 32
+	maxTracestateLength  = 512
+)
+
+type TraceID [16]byte
+
+func (t TraceID) IsValid() bool  { return t != TraceID{} }
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+type SpanID [8]byte
+
+func (s SpanID) IsValid() bool  { return s != SpanID{} }
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// SpanContext — то, что передаётся между сервисами: трасса, текущий спан,
+// флаги и tracestate вендоров. Remote — контекст пришёл из заголовков.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+	Remote     bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&FlagSampled != 0
+}
+
+// Traceparent форматирует контекст версии 00.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceparent разбирает заголовок traceparent. Версии новее 00
+// принимаются, если их начало совпадает с форматом 00; лишние поля
+// отбрасываются, как требует спецификация.
+func ParseTraceparent(s string) (SpanContext, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 55 {
+		return SpanContext{}, fmt.Errorf("traceparent %q: too short", s)
+	}
+	version, ok := parseHexByte(s[0:2])
+	if !ok || version == 0xff {
+		return SpanContext{}, fmt.Errorf("traceparent %q: invalid version", s)
+	}
+	if version == 0 && len(s) != 55 {
+		return SpanContext{}, fmt.Errorf("traceparent %q: invalid length", s)
+	}
+	if len(s) > 55 && s[55] != '-' {
+		return SpanContext{}, fmt.Errorf("traceparent %q: invalid format", s)
+	}
+	if s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return SpanContext{}, fmt.Errorf("traceparent %q: invalid format", s)
+	}
+
+	var sc SpanContext
+	if !decodeLowerHex(sc.TraceID[:], s[3:35]) || !sc.TraceID.IsValid() {
+		return SpanContext{}, fmt.Errorf("traceparent %q: invalid trace-id", s)
+	}
+	if !decodeLowerHex(sc.SpanID[:], s[36:52]) || !sc.SpanID.IsValid() {
+		return SpanContext{}, fmt.Errorf("traceparent %q: invalid parent-id", s)
+	}
+	if sc.Flags, ok = parseHexByte(s[53:55]); !ok {
+		return SpanContext{}, fmt.Errorf("traceparent %q: invalid trace-flags", s)
+	}
+	sc.Remote = true
+	return sc, nil
+}
+
+func parseHexByte(s string) (byte, bool) {
+	var b [1]byte
+	ok := decodeLowerHex(b[:], s)
+	return b[0], ok
+}
+
+// decodeLowerHex принимает только строчные hex-цифры, как требует формат.
+func decodeLowerHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+// normalizeTracestate убирает пустые элементы и пробелы вокруг них.
+// Слишком длинный или неразборчивый tracestate отбрасывается целиком.
+func normalizeTracestate(s string) string {
+	if len(s) > maxTracestateLength {
+		return ""
+	}
+	members := make([]string, 0, 4)
+	for _, member := range strings.Split(s, ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(member, "=")
+		if !ok || key == "" || value == "" || strings.ContainsAny(key, " \t") {
+			return ""
+		}
+		members = append(members, member)
+	}
+	if len(members) > maxTracestateMembers {
+		return ""
+	}
+	return strings.Join(members, ",")
+}
+
+// Extract читает traceparent и tracestate входящего запроса.
+func Extract(h http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.TraceState = normalizeTracestate(strings.Join(h.Values(TracestateHeader), ","))
+	return sc, true
+}
+
+// InjectSpanContext пишет заголовки для исходящего запроса.
+func InjectSpanContext(sc SpanContext, h http.Header) {
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// Exporter отправляет пачку завершённых спанов. Вызовы ExportSpans идут из
+// одной горутины трассировщика.
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// OpenExporter создаёт экспортёр по адресу: пусто — без экспорта,
+// "memory" — MemoryExporter, "http(s)://collector:4318" — OTLP/HTTP JSON.
+func OpenExporter(spec, serviceName string, headers map[string]string) (Exporter, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	if spec == "memory" {
+		return NewMemoryExporter(), nil
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return NewOTLPExporter(spec, serviceName, headers), nil
+	default:
+		return nil, fmt.Errorf("unsupported tracing exporter %q", spec)
+	}
+}
+
+// MemoryExporter хранит спаны в памяти — для тестов и отладки.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+func (e *MemoryExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *MemoryExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Spans возвращает экспортированные спаны в порядке завершения.
+func (e *MemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := make([]SpanData, len(e.spans))
+	copy(spans, e.spans)
+	return spans
+}
+
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
@@ -0,0 +1,301 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Config struct {
+	// ServiceName попадает в ресурс service.name при экспорте.
+	ServiceName string
+	// Exporter — куда отправлять спаны; nil — трасса только передаётся
+	// дальше по заголовкам, спаны не сохраняются.
+	Exporter Exporter
+	// SampleRatio — доля записываемых трасс, начатых этим сервисом: 0 — ни
+	// одной, 1 — все (DefaultConfig). Для трасс, пришедших снаружи, решение
+	// принимает вызывающая сторона (флаг sampled в traceparent).
+	SampleRatio float64
+
+	// Спаны копятся в очереди и отправляются пачками по BatchSize или
+	// раз в FlushInterval. Если очередь заполнена, спан теряется.
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	ExportTimeout time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ServiceName:   "go-showcase",
+		SampleRatio:   1,
+		QueueSize:     2048,
+		BatchSize:     512,
+		FlushInterval: 5 * time.Second,
+		ExportTimeout: 10 * time.Second,
+	}
+}
+
+// normalize заменяет нулевые и отрицательные значения значениями по
+// умолчанию. SampleRatio только ограничивается отрезком [0, 1]: 0 — осмысленное
+// значение, поэтому значение по умолчанию берётся из DefaultConfig.
+func (c Config) normalize() Config {
+	def := DefaultConfig()
+	if c.ServiceName == "" {
+		c.ServiceName = def.ServiceName
+	}
+	switch {
+	case !(c.SampleRatio >= 0): // отрицательное или NaN
+		c.SampleRatio = 0
+	case c.SampleRatio > 1:
+		c.SampleRatio = 1
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = def.QueueSize
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = def.BatchSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = def.FlushInterval
+	}
+	if c.ExportTimeout <= 0 {
+		c.ExportTimeout = def.ExportTimeout
+	}
+	return c
+}
+
+// Tracer создаёт спаны и отдаёт завершённые в Exporter из отдельной
+// горутины, чтобы медленный коллектор не задерживал запросы.
+type Tracer struct {
+	cfg Config
+	// sampleBound — порог для младших 63 бит trace-id (как в OpenTelemetry
+	// TraceIDRatioBased): решение одинаково на всех сервисах трассы.
+	sampleBound uint64
+
+	queue    chan SpanData
+	flush    chan chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	exported atomic.Uint64
+	dropped  atomic.Uint64
+	failed   atomic.Uint64
+}
+
+func NewTracer(cfg Config) *Tracer {
+	cfg = cfg.normalize()
+	t := &Tracer{
+		cfg:         cfg,
+		sampleBound: uint64(cfg.SampleRatio * (1 << 63)),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	if cfg.Exporter == nil {
+		close(t.stopped)
+		return t
+	}
+	t.queue = make(chan SpanData, cfg.QueueSize)
+	t.flush = make(chan chan struct{})
+	go t.run()
+	return t
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(NewTracer(DefaultConfig()))
+}
+
+// SetDefault задаёт трассировщик для Start вне существующей трассы.
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+type StartOption func(*SpanData)
+
+func WithKind(kind SpanKind) StartOption {
+	return func(d *SpanData) { d.Kind = kind }
+}
+
+func WithAttributes(attributes map[string]interface{}) StartOption {
+	return func(d *SpanData) {
+		for k, v := range attributes {
+			d.Attributes[k] = v
+		}
+	}
+}
+
+// Start начинает спан — дочерний к спану из ctx, к контексту из заголовков
+// или корневой — трассировщиком текущего спана, а без него — Default().
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	if parent := SpanFromContext(ctx); parent != nil {
+		return parent.tracer.Start(ctx, name, opts...)
+	}
+	return Default().Start(ctx, name, opts...)
+}
+
+func (t *Tracer) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID = parent.TraceID
+		sc.Flags = parent.Flags
+		sc.TraceState = parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+		if t.shouldSample(sc.TraceID) {
+			sc.Flags |= FlagSampled
+		}
+	}
+
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:        name,
+			SpanContext: sc,
+			Parent:      parent.SpanID,
+			Kind:        KindInternal,
+			Start:       time.Now(),
+			Attributes:  make(map[string]interface{}),
+		},
+	}
+	for _, opt := range opts {
+		opt(&span.data)
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) shouldSample(id TraceID) bool {
+	return binary.BigEndian.Uint64(id[8:])>>1 < t.sampleBound
+}
+
+func (t *Tracer) exporting() bool {
+	return t != nil && t.cfg.Exporter != nil
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	select {
+	case <-t.done:
+		t.dropped.Add(1)
+		return
+	default:
+	}
+	select {
+	case t.queue <- data:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.stopped)
+
+	ticker := time.NewTicker(t.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, t.cfg.BatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), t.cfg.ExportTimeout)
+		err := t.cfg.Exporter.ExportSpans(ctx, batch)
+		cancel()
+		if err != nil {
+			t.failed.Add(uint64(len(batch)))
+			slog.Warn("tracing export failed", "spans", len(batch), "error", err)
+		} else {
+			t.exported.Add(uint64(len(batch)))
+		}
+		batch = make([]SpanData, 0, t.cfg.BatchSize)
+	}
+	drain := func() {
+		for {
+			select {
+			case data := <-t.queue:
+				batch = append(batch, data)
+				if len(batch) >= t.cfg.BatchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) >= t.cfg.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-t.flush:
+			drain()
+			close(ack)
+		case <-t.done:
+			drain()
+			return
+		}
+	}
+}
+
+// ForceFlush отправляет все завершённые к этому моменту спаны.
+func (t *Tracer) ForceFlush(ctx context.Context) error {
+	if !t.exporting() {
+		return nil
+	}
+	ack := make(chan struct{})
+	select {
+	case t.flush <- ack:
+	case <-t.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown отправляет оставшиеся спаны и закрывает экспортёр. Спаны,
+// завершённые после Shutdown, отбрасываются.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.stopOnce.Do(func() { close(t.done) })
+	select {
+	case <-t.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if t.cfg.Exporter != nil {
+		return t.cfg.Exporter.Shutdown(ctx)
+	}
+	return nil
+}
+
+func (t *Tracer) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"service":      t.cfg.ServiceName,
+		"exporting":    t.exporting(),
+		"sample_ratio": t.cfg.SampleRatio,
+		"exported":     t.exported.Load(),
+		"dropped":      t.dropped.Load(),
+		"failed":       t.failed.Load(),
+	}
+}
@@ -0,0 +1,143 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultBrokerChannel = "go-showcase:ws"
+
+// Envelope — сообщение в пути между репликами. Origin и Seq позволяют
+// каждому хабу отбросить дубликаты, которые брокер доставил повторно.
+type Envelope struct {
+	Origin  string  `json:"origin"`
+	Seq     uint64  `json:"seq"`
+	Message Message `json:"message"`
+}
+
+// Broker рассылает широковещательные сообщения всем подписанным хабам,
+// в том числе опубликовавшему. Хаб отдаёт свои сообщения локальным клиентам
+// сам и отбрасывает их эхо по Origin.
+type Broker interface {
+	Publish(ctx context.Context, env Envelope) error
+	Subscribe(handler func(Envelope)) (unsubscribe func(), err error)
+	Close() error
+}
+
+// OpenBroker создаёт брокер по адресу: "memory" или
+// "redis://host:port/channel" (канал по умолчанию — go-showcase:ws).
+func OpenBroker(rawURL string) (Broker, error) {
+	if rawURL == "memory" {
+		return NewMemoryBroker(), nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "redis":
+		channel := strings.TrimPrefix(u.Path, "/")
+		if channel == "" {
+			channel = defaultBrokerChannel
+		}
+		return NewRedisBroker(u.Host, channel), nil
+	default:
+		return nil, fmt.Errorf("unsupported broker %q", rawURL)
+	}
+}
+
+// MemoryBroker — брокер внутри процесса. Подходит для одного экземпляра
+// сервера и как замена сетевого брокера, когда несколько хабов живут в
+// одном процессе.
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers map[int]func(Envelope)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{handlers: make(map[int]func(Envelope))}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, env Envelope) error {
+	b.mu.RLock()
+	handlers := make([]func(Envelope), 0, len(b.handlers))
+	for _, handler := range b.handlers {
+		handlers = append(handlers, handler)
+	}
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		handler(env)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(handler func(Envelope)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}, nil
+}
+
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = make(map[int]func(Envelope))
+	return nil
+}
+
+const (
+	dedupWindow    = 64
+	originIdleTTL  = 10 * time.Minute
+	publishTimeout = 2 * time.Second
+)
+
+// seqWindow помнит последние dedupWindow номеров одного источника:
+// max — наибольший увиденный, бит i в seen — номер max-i.
+type seqWindow struct {
+	max      uint64
+	seen     uint64
+	lastSeen time.Time
+}
+
+// accept возвращает false для уже виденного или слишком старого номера.
+func (w *seqWindow) accept(seq uint64) bool {
+	switch {
+	case seq > w.max:
+		shift := seq - w.max
+		if shift >= dedupWindow {
+			w.seen = 0
+		} else {
+			w.seen <<= shift
+		}
+		w.seen |= 1
+		w.max = seq
+		return true
+	case w.max-seq >= dedupWindow:
+		return false
+	default:
+		bit := uint64(1) << (w.max - seq)
+		if w.seen&bit != 0 {
+			return false
+		}
+		w.seen |= bit
+		return true
+	}
+}
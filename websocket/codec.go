@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	ProtocolJSON    = "showcase.json"
+	ProtocolMsgpack = "showcase.msgpack"
+)
+
+// TypeBatch — несколько сообщений, склеенных в один кадр: data содержит
+// массив сообщений. Отправляется только клиентам, выбравшим подпротокол.
+const TypeBatch = "batch"
+
+type Codec interface {
+	Subprotocol() string
+	FrameType() int
+	Encode(msg Message) ([]byte, error)
+	Decode(data []byte, msg *Message) error
+}
+
+type jsonCodec struct {
+	protocol string
+}
+
+func (c jsonCodec) Subprotocol() string { return c.protocol }
+func (c jsonCodec) FrameType() int      { return websocket.TextMessage }
+
+func (c jsonCodec) Encode(msg Message) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (c jsonCodec) Decode(data []byte, msg *Message) error {
+	return json.Unmarshal(data, msg)
+}
+
+// msgpackCodec кодирует то же JSON-представление сообщения, но в
+// MessagePack: набор полей и их имена у обоих форматов совпадают.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Subprotocol() string { return ProtocolMsgpack }
+func (msgpackCodec) FrameType() int      { return websocket.BinaryMessage }
+
+func (msgpackCodec) Encode(msg Message) ([]byte, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return msgpackAppend(nil, generic)
+}
+
+func (msgpackCodec) Decode(data []byte, msg *Message) error {
+	generic, err := msgpackUnmarshal(data)
+	if err != nil {
+		return err
+	}
+	data, err = json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, msg)
+}
+
+var (
+	// legacyCodec — для клиентов без подпротокола: JSON, кадр на сообщение.
+	legacyCodec Codec = jsonCodec{}
+
+	codecs = map[string]Codec{
+		ProtocolJSON:    jsonCodec{protocol: ProtocolJSON},
+		ProtocolMsgpack: msgpackCodec{},
+	}
+)
+
+// NegotiateCodec выбирает первый поддерживаемый подпротокол из
+// предложенных клиентом (в порядке клиента). Если подходящего нет,
+// используется JSON без подпротокола.
+func NegotiateCodec(r *http.Request) Codec {
+	for _, protocol := range websocketSubprotocols(r) {
+		if codec, ok := codecs[protocol]; ok {
+			return codec
+		}
+	}
+	return legacyCodec
+}
+
+func decodeFrame(codec Codec, frameType int, data []byte, msg *Message) error {
+	if frameType == websocket.TextMessage {
+		return json.Unmarshal(data, msg)
+	}
+	return codec.Decode(data, msg)
+}
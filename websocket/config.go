@@ -0,0 +1,174 @@
+package websocket
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"go-showcase/middleware"
+)
+
+// BackpressurePolicy определяет, что делать с сообщением, когда очередь
+// клиента (канал Send) заполнена.
+type BackpressurePolicy int
+
+const (
+	// Disconnect отключает клиента, не успевающего разбирать очередь.
+	Disconnect BackpressurePolicy = iota
+	// DropNewest отбрасывает новое сообщение, очередь не меняется.
+	DropNewest
+	// DropOldest выталкивает самое старое сообщение из очереди.
+	DropOldest
+	// Block ждёт места в очереди до BlockTimeout, затем отключает клиента.
+	// Пока хаб ждёт, остальные клиенты тоже не получают сообщений; срок
+	// общий на всю рассылку, а не на каждого медленного клиента.
+	Block
+)
+
+var backpressureNames = map[BackpressurePolicy]string{
+	Disconnect: "disconnect",
+	DropNewest: "drop_newest",
+	DropOldest: "drop_oldest",
+	Block:      "block",
+}
+
+func (p BackpressurePolicy) String() string {
+	if name, ok := backpressureNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("BackpressurePolicy(%d)", int(p))
+}
+
+func ParseBackpressurePolicy(s string) (BackpressurePolicy, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for policy, name := range backpressureNames {
+		if name == s {
+			return policy, nil
+		}
+	}
+	return Disconnect, fmt.Errorf("unknown backpressure policy %q", s)
+}
+
+type HubConfig struct {
+	// WriteWait — время на запись одного кадра.
+	WriteWait time.Duration
+	// PongWait — сколько ждать pong (или любого кадра) от клиента.
+	PongWait time.Duration
+	// PingPeriod должен быть меньше PongWait.
+	PingPeriod        time.Duration
+	HeartbeatInterval time.Duration
+
+	ReadBufferSize  int
+	WriteBufferSize int
+	// SendBufferSize — длина очереди исходящих сообщений клиента.
+	SendBufferSize int
+
+	Backpressure BackpressurePolicy
+	BlockTimeout time.Duration
+
+	MaxInflightRequests int
+	RPCTimeout          time.Duration
+
+	MaxBatchSize      int
+	EnableCompression bool
+	// CompressionThreshold — короткие кадры сжимать невыгодно.
+	CompressionThreshold int
+
+	// Logger — логгер хаба; nil — slog.Default(). Клиенты пишут через
+	// логгер запроса на апгрейд (см. Client.SetLogger).
+	Logger *slog.Logger
+	// Recoverer перехватывает паники в горутинах хаба и клиентов; nil —
+	// паники только пишутся в лог.
+	Recoverer *middleware.Recoverer
+}
+
+func DefaultHubConfig() HubConfig {
+	return HubConfig{
+		WriteWait:            10 * time.Second,
+		PongWait:             60 * time.Second,
+		PingPeriod:           54 * time.Second,
+		HeartbeatInterval:    30 * time.Second,
+		ReadBufferSize:       1024,
+		WriteBufferSize:      1024,
+		SendBufferSize:       256,
+		Backpressure:         Disconnect,
+		BlockTimeout:         100 * time.Millisecond,
+		MaxInflightRequests:  8,
+		RPCTimeout:           10 * time.Second,
+		MaxBatchSize:         32,
+		EnableCompression:    true,
+		CompressionThreshold: 512,
+	}
+}
+
+func (c *HubConfig) logger() *slog.Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
+}
+
+var defaultRecoverer = middleware.NewRecoverer(nil)
+
+func (c *HubConfig) recoverer() *middleware.Recoverer {
+	if c.Recoverer == nil {
+		return defaultRecoverer
+	}
+	return c.Recoverer
+}
+
+// normalize заменяет нулевые и отрицательные значения значениями по
+// умолчанию и не даёт PingPeriod дорасти до PongWait: иначе соединение
+// рвётся по таймауту чтения раньше, чем уйдёт ping.
+func (c HubConfig) normalize() HubConfig {
+	def := DefaultHubConfig()
+
+	durations := []struct{ v, def *time.Duration }{
+		{&c.WriteWait, &def.WriteWait},
+		{&c.PongWait, &def.PongWait},
+		{&c.HeartbeatInterval, &def.HeartbeatInterval},
+		{&c.BlockTimeout, &def.BlockTimeout},
+		{&c.RPCTimeout, &def.RPCTimeout},
+	}
+	for _, d := range durations {
+		if *d.v <= 0 {
+			*d.v = *d.def
+		}
+	}
+
+	sizes := []struct{ v, def *int }{
+		{&c.ReadBufferSize, &def.ReadBufferSize},
+		{&c.WriteBufferSize, &def.WriteBufferSize},
+		{&c.SendBufferSize, &def.SendBufferSize},
+		{&c.MaxInflightRequests, &def.MaxInflightRequests},
+		{&c.MaxBatchSize, &def.MaxBatchSize},
+	}
+	for _, s := range sizes {
+		if *s.v <= 0 {
+			*s.v = *s.def
+		}
+	}
+	if c.CompressionThreshold < 0 {
+		c.CompressionThreshold = 0
+	}
+
+	if c.PingPeriod <= 0 || c.PingPeriod >= c.PongWait {
+		c.PingPeriod = c.PongWait * 9 / 10
+	}
+	return c
+}
+
+// Upgrader возвращает апгрейдер с размерами буферов и сжатием из конфигурации.
+func (c HubConfig) Upgrader(checkOrigin func(r *http.Request) bool) websocket.Upgrader {
+	c = c.normalize()
+	return websocket.Upgrader{
+		ReadBufferSize:    c.ReadBufferSize,
+		WriteBufferSize:   c.WriteBufferSize,
+		EnableCompression: c.EnableCompression,
+		CheckOrigin:       checkOrigin,
+	}
+}
@@ -0,0 +1,242 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"go-showcase/middleware"
+	"go-showcase/problem"
+)
+
+// bearerProtocolPrefix — браузер не умеет ставить заголовки на WebSocket,
+// поэтому токен можно передать подпротоколом "bearer.<token>".
+const bearerProtocolPrefix = "bearer."
+
+type GuardConfig struct {
+	// AllowedOrigins — разрешённые значения заголовка Origin ("*" — любой).
+	// Пустой список означает same-origin.
+	AllowedOrigins []string
+
+	Authenticator middleware.Authenticator
+	RequireAuth   bool
+
+	MaxConnections      int
+	MaxConnectionsPerIP int
+
+	MaxMessageSize   int64
+	MessageRate      rate.Limit
+	MessageBurst     int
+	MessageAlgorithm middleware.Algorithm
+	// MessageStore — хранилище лимитов сообщений; nil — своё в памяти.
+	MessageStore middleware.RateLimitStore
+
+	// ClientIP определяет адрес клиента (с учётом доверенных прокси);
+	// nil — адрес TCP-соединения.
+	ClientIP func(r *http.Request) string
+}
+
+func DefaultGuardConfig() GuardConfig {
+	return GuardConfig{
+		MaxConnections:      1000,
+		MaxConnectionsPerIP: 20,
+		MaxMessageSize:      64 * 1024,
+		MessageRate:         rate.Limit(20),
+		MessageBurst:        40,
+	}
+}
+
+// Guard решает, пускать ли соединение на этапе апгрейда, и ограничивает
+// уже установленные соединения.
+type Guard struct {
+	cfg GuardConfig
+
+	mu    sync.Mutex
+	total int
+	perIP map[string]int
+}
+
+type Admission struct {
+	IP          string
+	Principal   *middleware.Principal
+	Subprotocol string
+
+	release sync.Once
+}
+
+func NewGuard(cfg GuardConfig) *Guard {
+	if cfg.MessageRate > 0 && cfg.MessageStore == nil {
+		cfg.MessageStore = middleware.NewMemoryStore()
+	}
+	if cfg.ClientIP == nil {
+		cfg.ClientIP = middleware.RemoteIP
+	}
+	return &Guard{
+		cfg:   cfg,
+		perIP: make(map[string]int),
+	}
+}
+
+func (g *Guard) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if len(g.cfg.AllowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+
+	for _, allowed := range g.cfg.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Admit проверяет Origin, токен и лимиты соединений. Успешный Admit занимает
+// слот, который нужно вернуть через Release после закрытия соединения.
+// Отказ возвращается как *problem.Error и отдаётся клиенту через problem.Write.
+func (g *Guard) Admit(r *http.Request) (*Admission, error) {
+	if !g.CheckOrigin(r) {
+		return nil, problem.New(problem.OriginNotAllowed)
+	}
+
+	admission := &Admission{IP: g.cfg.ClientIP(r)}
+
+	token, subprotocol := requestToken(r)
+	if token != "" {
+		if g.cfg.Authenticator == nil {
+			return nil, problem.New(problem.AuthNotConfigured)
+		}
+		principal, ok := g.cfg.Authenticator.Authenticate(token)
+		if !ok {
+			return nil, problem.New(problem.InvalidToken)
+		}
+		admission.Principal = principal
+		admission.Subprotocol = subprotocol
+	} else if g.cfg.RequireAuth {
+		return nil, problem.New(problem.AuthenticationRequired)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.cfg.MaxConnections > 0 && g.total >= g.cfg.MaxConnections {
+		return nil, problem.New(problem.TooManyConnections)
+	}
+	if g.cfg.MaxConnectionsPerIP > 0 && g.perIP[admission.IP] >= g.cfg.MaxConnectionsPerIP {
+		return nil, problem.New(problem.TooManyConnectionsIP)
+	}
+
+	g.total++
+	g.perIP[admission.IP]++
+	return admission, nil
+}
+
+// ClientID — стабильный идентификатор клиента: ID пользователя для
+// аутентифицированных соединений и случайный anon_… для анонимных.
+func (a *Admission) ClientID() string {
+	if a.Principal != nil {
+		return a.Principal.ID
+	}
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return "anon_" + hex.EncodeToString(buf)
+}
+
+func (g *Guard) Release(a *Admission) {
+	a.release.Do(func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		g.total--
+		if g.perIP[a.IP]--; g.perIP[a.IP] <= 0 {
+			delete(g.perIP, a.IP)
+		}
+	})
+}
+
+// Attach переносит результат Admit на клиента: личность, лимит на размер
+// входящего сообщения и лимит частоты сообщений. Лимит общий для всех
+// соединений одного пользователя (или одного IP для анонимов).
+func (g *Guard) Attach(c *Client, a *Admission) {
+	c.Principal = a.Principal
+	c.IP = a.IP
+	c.maxMessageSize = g.cfg.MaxMessageSize
+
+	if g.cfg.MessageRate > 0 {
+		key := "ws:ip:" + a.IP
+		if a.Principal != nil {
+			key = "ws:user:" + a.Principal.ID
+		}
+		c.limits = g.cfg.MessageStore
+		c.limitKey = key
+		c.limit = middleware.Limit{
+			Algorithm: g.cfg.MessageAlgorithm,
+			Rate:      g.cfg.MessageRate,
+			Burst:     g.cfg.MessageBurst,
+		}
+	}
+}
+
+// allowMessage расходует лимит сообщений клиента. Если хранилище лимитов
+// недоступно, сообщение пропускается.
+func (c *Client) allowMessage(ctx context.Context) middleware.Decision {
+	if c.limits == nil {
+		return middleware.Decision{Allowed: true}
+	}
+	decision, err := c.limits.Take(ctx, c.limitKey, c.limit)
+	if err != nil {
+		middleware.LoggerFromContext(ctx).Error("websocket rate limit store unavailable", "error", err)
+		return middleware.Decision{Allowed: true}
+	}
+	return decision
+}
+
+func (g *Guard) Stats() map[string]interface{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return map[string]interface{}{
+		"connections":      g.total,
+		"unique_addresses": len(g.perIP),
+	}
+}
+
+func requestToken(r *http.Request) (token, subprotocol string) {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token, ""
+	}
+
+	for _, protocol := range websocketSubprotocols(r) {
+		if strings.HasPrefix(protocol, bearerProtocolPrefix) {
+			return strings.TrimPrefix(protocol, bearerProtocolPrefix), protocol
+		}
+	}
+
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer "), ""
+	}
+	return "", ""
+}
+
+func websocketSubprotocols(r *http.Request) []string {
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-Websocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			if protocol = strings.TrimSpace(protocol); protocol != "" {
+				protocols = append(protocols, protocol)
+			}
+		}
+	}
+	return protocols
+}
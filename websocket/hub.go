@@ -1,93 +1,256 @@
 package websocket
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
+	"log/slog"
+	"math"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"go-showcase/i18n"
+	"go-showcase/middleware"
+	"go-showcase/problem"
+	"go-showcase/tracing"
 )
 
 type Message struct {
-	Type      string      `json:"type"`
-	Data      interface{} `json:"data"`
-	Timestamp time.Time   `json:"timestamp"`
+	Type      string          `json:"type"`
+	ID        string          `json:"id,omitempty"`
+	From      string          `json:"from,omitempty"`
+	To        string          `json:"to,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Data      interface{}     `json:"data,omitempty"`
+	Error     *RPCError       `json:"error,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
 }
 
 type Client struct {
-	ID   string
-	Conn *websocket.Conn
-	Send chan Message
+	ID        string
+	Conn      *websocket.Conn
+	Send      chan Message
+	Principal *middleware.Principal
+	// IP — адрес клиента при апгрейде (с учётом доверенных прокси).
+	IP string
+
+	cfg            *HubConfig
+	logger         *slog.Logger
+	codec          Codec
+	lang           string
+	inflight       chan struct{}
+	limits         middleware.RateLimitStore
+	limitKey       string
+	limit          middleware.Limit
+	maxMessageSize int64
+
+	// topics, connectedAt и dropped принадлежат горутине Hub.Run.
+	topics      map[string]bool
+	connectedAt time.Time
+	dropped     uint64
 }
 
+// NewClient создаёт клиента с очередью и лимитом запросов из конфигурации хаба.
+func (h *Hub) NewClient(id string, conn *websocket.Conn) *Client {
+	return &Client{
+		ID:       id,
+		Conn:     conn,
+		Send:     make(chan Message, h.cfg.SendBufferSize),
+		cfg:      &h.cfg,
+		logger:   h.cfg.logger().With("client_id", id),
+		inflight: make(chan struct{}, h.cfg.MaxInflightRequests),
+	}
+}
+
+// SetLogger задаёт логгер клиента — обычно логгер запроса на апгрейд,
+// чтобы записи о соединении несли его request_id.
+func (c *Client) SetLogger(logger *slog.Logger) {
+	c.logger = logger.With("client_id", c.ID)
+}
+
+func (c *Client) log() *slog.Logger {
+	if c.logger == nil {
+		return c.config().logger().With("client_id", c.ID)
+	}
+	return c.logger
+}
+
+var defaultConfig = DefaultHubConfig()
+
+// config нужен клиентам, созданным без NewClient.
+func (c *Client) config() *HubConfig {
+	if c.cfg == nil {
+		return &defaultConfig
+	}
+	return c.cfg
+}
+
+// directMessage адресуется либо конкретному соединению (client), либо всем
+// соединениям клиента с идентификатором to. Если задан from, об отсутствии
+// получателя сообщается отправителю.
+type directMessage struct {
+	client *Client
+	to     string
+	from   *Client
+	msg    Message
+}
+
+// Hub владеет множеством клиентов: карта clients читается и изменяется
+// только горутиной Run, остальные методы общаются с ней через каналы.
+// Поэтому канал client.Send закрывается ровно один раз и только здесь.
 type Hub struct {
-	clients    map[*Client]bool
+	cfg HubConfig
+
+	clients     map[*Client]bool
+	byID        map[string]map[*Client]bool
+	subscribers map[string]map[*Client]bool
+	closing     bool
+	origins     map[string]*seqWindow
+	dropped     uint64
+	evicted     uint64
+	// blockUntil — общий срок ожидания политики Block на время одной
+	// рассылки (см. shareBlockTimeout); нулевой — каждый deliver ждёт
+	// BlockTimeout сам.
+	blockUntil time.Time
+
+	broker      Broker
+	unsubscribe func()
+	origin      string
+	seq         atomic.Uint64
+
 	broadcast  chan Message
+	inbound    chan Envelope
 	register   chan *Client
 	unregister chan *Client
-	mu         sync.RWMutex
+	direct     chan directMessage
+	subscribe  chan subscription
+	presence   chan chan []PresenceInfo
+	stats      chan chan map[string]interface{}
+	shutdown   chan chan struct{}
+	done       chan struct{}
+
+	rpcMu sync.RWMutex
+	rpc   map[string]RPCHandler
 }
 
 func NewHub() *Hub {
+	return NewHubWithConfig(DefaultHubConfig())
+}
+
+// NewHubWithConfig создаёт хаб; нулевые поля cfg заменяются значениями
+// из DefaultHubConfig.
+func NewHubWithConfig(cfg HubConfig) *Hub {
+	origin := make([]byte, 8)
+	rand.Read(origin)
+
 	return &Hub{
-		clients:    make(map[*Client]bool),
-		broadcast:  make(chan Message, 256),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+		cfg:         cfg.normalize(),
+		origins:     make(map[string]*seqWindow),
+		origin:      hex.EncodeToString(origin),
+		inbound:     make(chan Envelope, 256),
+		clients:     make(map[*Client]bool),
+		byID:        make(map[string]map[*Client]bool),
+		subscribers: make(map[string]map[*Client]bool),
+		broadcast:   make(chan Message, 256),
+		register:    make(chan *Client),
+		unregister:  make(chan *Client),
+		direct:      make(chan directMessage, 256),
+		subscribe:   make(chan subscription),
+		presence:    make(chan chan []PresenceInfo),
+		stats:       make(chan chan map[string]interface{}),
+		shutdown:    make(chan chan struct{}),
+		done:        make(chan struct{}),
+		rpc:         make(map[string]RPCHandler),
 	}
 }
 
 func (h *Hub) Register(client *Client) {
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.done:
+		// Хаб уже остановлен: клиент так и не попал в карту, поэтому
+		// закрыть его канал здесь безопасно — WritePump завершится.
+		close(client.Send)
+	}
 }
 
+func (h *Hub) Unregister(client *Client) {
+	select {
+	case h.unregister <- client:
+	case <-h.done:
+	}
+}
+
+// Run обслуживает хаб до Shutdown. Если цикл прервала паника, он
+// запускается снова: состояние хаба принадлежит только этой горутине,
+// а сообщение, на котором случилась паника, уже вынуто из канала.
 func (h *Hub) Run() {
-	ticker := time.NewTicker(30 * time.Second)
+	for !h.runLoop() {
+	}
+}
+
+func (h *Hub) runLoop() (stopped bool) {
+	ctx := middleware.WithLogger(context.Background(), h.cfg.logger())
+	defer h.cfg.recoverer().Recover(ctx, "websocket.hub")
+
+	ticker := time.NewTicker(h.cfg.HeartbeatInterval)
 	defer ticker.Stop()
 	
 	for {
 		select {
 		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client] = true
-			h.mu.Unlock()
-			fmt.Printf("WebSocket: Клиент подключен (ID: %s). Всего клиентов: %d\n", 
-				client.ID, len(h.clients))
+			h.add(client)
+			client.log().Info("websocket client connected", "clients", len(h.clients))
 			
-			h.SendToClient(client, Message{
+			h.deliver(client, Message{
 				Type: "welcome",
 				Data: map[string]interface{}{
-					"message": "Добро пожаловать в Go Showcase WebSocket!",
+					"message": i18n.Lang(client.Language()).T("ws.welcome"),
 					"id":      client.ID,
 				},
 				Timestamp: time.Now(),
 			})
 			
 		case client := <-h.unregister:
-			if _, ok := h.clients[client]; ok {
-				h.mu.Lock()
-				delete(h.clients, client)
-				close(client.Send)
-				h.mu.Unlock()
-				fmt.Printf("WebSocket: Клиент отключен (ID: %s). Всего клиентов: %d\n", 
-					client.ID, len(h.clients))
+			if h.remove(client) {
+				client.log().Info("websocket client disconnected", "clients", len(h.clients))
 			}
 			
 		case message := <-h.broadcast:
-			h.mu.RLock()
-			for client := range h.clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(h.clients, client)
-				}
+			h.fanout(message)
+
+		case env := <-h.inbound:
+			if h.accept(env) {
+				h.fanout(env.Message)
 			}
-			h.mu.RUnlock()
+
+		case dm := <-h.direct:
+			h.route(dm)
+
+		case sub := <-h.subscribe:
+			h.updateSubscription(sub)
+
+		case reply := <-h.presence:
+			reply <- h.presenceList()
+
+		case reply := <-h.stats:
+			reply <- h.statsSnapshot()
+
+		case ack := <-h.shutdown:
+			h.closeAll()
+			close(h.done)
+			close(ack)
+			return true
 			
 		case <-ticker.C:
-			h.BroadcastMessage(Message{
+			h.pruneOrigins()
+			h.fanout(Message{
 				Type: "heartbeat",
 				Data: map[string]interface{}{
 					"active_clients": len(h.clients),
@@ -99,74 +262,371 @@ func (h *Hub) Run() {
 	}
 }
 
-func (h *Hub) BroadcastMessage(msg Message) {
-	h.broadcast <- msg
-}
-
-func (h *Hub) SendToClient(client *Client, msg Message) {
+// deliver кладёт сообщение в очередь клиента. Если очередь заполнена,
+// поступает согласно cfg.Backpressure.
+func (h *Hub) deliver(client *Client, msg Message) {
+	if !h.clients[client] {
+		return
+	}
 	select {
 	case client.Send <- msg:
+		return
 	default:
-		close(client.Send)
-		h.mu.Lock()
-		delete(h.clients, client)
-		h.mu.Unlock()
+	}
+
+	switch h.cfg.Backpressure {
+	case DropNewest:
+		h.drop(client)
+
+	case DropOldest:
+		select {
+		case <-client.Send:
+			h.drop(client)
+		default:
+			// WritePump успел освободить очередь сам.
+		}
+		// Хаб — единственный отправитель в client.Send, поэтому место
+		// в очереди теперь есть и запись не заблокируется.
+		client.Send <- msg
+
+	case Block:
+		deadline := h.blockUntil
+		if deadline.IsZero() {
+			deadline = time.Now().Add(h.cfg.BlockTimeout)
+		}
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		select {
+		case client.Send <- msg:
+		case <-timer.C:
+			h.evict(client)
+		}
+
+	default:
+		h.evict(client)
 	}
 }
 
-func (h *Hub) GetStats() map[string]interface{} {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
-	
+func (h *Hub) drop(client *Client) {
+	client.dropped++
+	h.dropped++
+}
+
+func (h *Hub) evict(client *Client) {
+	h.drop(client)
+	h.evicted++
+	h.remove(client)
+	client.log().Warn("websocket slow client evicted",
+		"policy", h.cfg.Backpressure.String(),
+		"dropped", client.dropped)
+}
+
+func (h *Hub) statsSnapshot() map[string]interface{} {
+	clients := make([]map[string]interface{}, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, map[string]interface{}{
+			"id":       client.ID,
+			"queued":   len(client.Send),
+			"capacity": cap(client.Send),
+			"dropped":  client.dropped,
+		})
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i]["id"].(string) < clients[j]["id"].(string)
+	})
+
 	return map[string]interface{}{
-		"total_clients": len(h.clients),
-		"timestamp":     time.Now(),
+		"total_clients":    len(h.clients),
+		"unique_clients":   len(h.byID),
+		"backpressure":     h.cfg.Backpressure.String(),
+		"dropped_messages": h.dropped,
+		"evicted_clients":  h.evicted,
+		"clients":          clients,
+		"timestamp":        time.Now(),
 	}
 }
 
-func (h *Hub) Shutdown() {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	
-	shutdownMsg := Message{
-		Type: "shutdown",
-		Data: map[string]interface{}{
-			"message": "Server is shutting down gracefully",
-		},
-		Timestamp: time.Now(),
+// shareBlockTimeout выполняет рассылку fn с одним сроком ожидания на всех
+// получателей: сколько бы медленных клиентов ни было, Run простаивает не
+// дольше BlockTimeout, а после срока переполненные очереди отключаются
+// сразу. Вложенные рассылки (presence при вытеснении) делят тот же срок.
+func (h *Hub) shareBlockTimeout(fn func()) {
+	if h.blockUntil.IsZero() {
+		h.blockUntil = time.Now().Add(h.cfg.BlockTimeout)
+		defer func() { h.blockUntil = time.Time{} }()
 	}
-	
+	fn()
+}
+
+func (h *Hub) fanout(msg Message) {
+	h.shareBlockTimeout(func() {
+		for client := range h.clients {
+			h.deliver(client, msg)
+		}
+	})
+}
+
+func (h *Hub) add(client *Client) {
+	h.clients[client] = true
+	client.connectedAt = time.Now()
+
+	connections, ok := h.byID[client.ID]
+	if !ok {
+		connections = make(map[*Client]bool)
+		h.byID[client.ID] = connections
+	}
+	connections[client] = true
+	if len(connections) == 1 {
+		h.publishPresence(PresenceJoined, client)
+	}
+}
+
+func (h *Hub) remove(client *Client) bool {
+	if !h.clients[client] {
+		return false
+	}
+	delete(h.clients, client)
+	close(client.Send)
+
+	for topic := range client.topics {
+		delete(h.subscribers[topic], client)
+	}
+
+	if connections := h.byID[client.ID]; connections != nil {
+		delete(connections, client)
+		if len(connections) == 0 {
+			delete(h.byID, client.ID)
+			h.publishPresence(PresenceLeft, client)
+		}
+	}
+	return true
+}
+
+func (h *Hub) route(dm directMessage) {
+	if dm.client != nil {
+		h.deliver(dm.client, dm.msg)
+		return
+	}
+
+	targets := h.byID[dm.to]
+	if len(targets) == 0 {
+		if dm.from != nil {
+			h.deliver(dm.from, Message{
+				Type:      TypeError,
+				ID:        dm.msg.ID,
+				To:        dm.to,
+				Error:     clientError(dm.from, problem.New(problem.RecipientOffline, dm.to)),
+				Timestamp: time.Now(),
+			})
+		}
+		return
+	}
+	h.shareBlockTimeout(func() {
+		for target := range targets {
+			h.deliver(target, dm.msg)
+		}
+	})
+}
+
+func (h *Hub) closeAll() {
+	h.closing = true
 	for client := range h.clients {
-		client.Send <- shutdownMsg
-		close(client.Send)
-		client.Conn.Close()
+		shutdownMsg := Message{
+			Type: "shutdown",
+			Data: map[string]interface{}{
+				"message": i18n.Lang(client.Language()).T("ws.shutdown"),
+			},
+			Timestamp: time.Now(),
+		}
+		select {
+		case client.Send <- shutdownMsg:
+		default:
+		}
+		h.remove(client)
+	}
+}
+
+// UseBroker подключает хаб к брокеру; вызывать до Run. После этого
+// BroadcastMessage доходит до клиентов всех реплик, подписанных на брокер.
+func (h *Hub) UseBroker(b Broker) error {
+	unsubscribe, err := b.Subscribe(func(env Envelope) {
+		// Своим клиентам хаб доставляет сообщение сам в BroadcastMessage:
+		// эхо от брокера было бы дубликатом.
+		if env.Origin != h.origin {
+			h.receive(env)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	h.broker = b
+	h.unsubscribe = unsubscribe
+	return nil
+}
+
+func (h *Hub) receive(env Envelope) {
+	select {
+	case h.inbound <- env:
+	case <-h.done:
+	}
+}
+
+// accept отбрасывает конверты, которые брокер доставил повторно.
+func (h *Hub) accept(env Envelope) bool {
+	window, ok := h.origins[env.Origin]
+	if !ok {
+		window = &seqWindow{}
+		h.origins[env.Origin] = window
+	}
+	window.lastSeen = time.Now()
+	return window.accept(env.Seq)
+}
+
+func (h *Hub) pruneOrigins() {
+	for origin, window := range h.origins {
+		if time.Since(window.lastSeen) > originIdleTTL {
+			delete(h.origins, origin)
+		}
+	}
+}
+
+// BroadcastMessage рассылает сообщение всем клиентам. Из ctx берётся
+// только логгер: публикация не прерывается вместе с запросом.
+func (h *Hub) BroadcastMessage(ctx context.Context, msg Message) {
+	ctx, span := tracing.Start(ctx, "websocket.broadcast", tracing.WithKind(tracing.KindProducer))
+	defer span.End()
+	span.SetAttribute("websocket.message.type", msg.Type)
+	span.SetAttribute("websocket.broker", h.broker != nil)
+
+	// Локальные клиенты получают сообщение напрямую, а не через эхо брокера:
+	// пока подписка на брокер не установлена или переподключается, PUBLISH
+	// проходит, но обратно ничего не приходит.
+	if h.broker != nil {
+		env := Envelope{Origin: h.origin, Seq: h.seq.Add(1), Message: msg}
+
+		publishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), publishTimeout)
+		err := h.broker.Publish(publishCtx, env)
+		cancel()
+		if err != nil {
+			span.RecordError(err)
+			middleware.LoggerFromContext(ctx).Warn("websocket broker publish failed", "error", err)
+		}
+	}
+
+	select {
+	case h.broadcast <- msg:
+	case <-h.done:
+	}
+}
+
+func (h *Hub) SendToClient(client *Client, msg Message) {
+	select {
+	case h.direct <- directMessage{client: client, msg: msg}:
+	case <-h.done:
+	}
+}
+
+// SendTo доставляет сообщение всем соединениям клиента с данным ID.
+func (h *Hub) SendTo(id string, msg Message) {
+	select {
+	case h.direct <- directMessage{to: id, msg: msg}:
+	case <-h.done:
+	}
+}
+
+func (h *Hub) GetStats() map[string]interface{} {
+	reply := make(chan map[string]interface{}, 1)
+	select {
+	case h.stats <- reply:
+		return <-reply
+	case <-h.done:
+		return map[string]interface{}{
+			"total_clients": 0,
+			"timestamp":     time.Now(),
+		}
 	}
+}
 	
-	h.clients = make(map[*Client]bool)
-	fmt.Printf("WebSocket: All clients disconnected\n")
+// Shutdown отправляет всем клиентам уведомление (если в их очереди есть
+// место), закрывает их каналы и останавливает Run. Повторный вызов безопасен.
+func (h *Hub) Shutdown() {
+	ack := make(chan struct{})
+	select {
+	case h.shutdown <- ack:
+		<-ack
+		if h.unsubscribe != nil {
+			h.unsubscribe()
+		}
+		h.cfg.logger().Info("websocket clients disconnected")
+	case <-h.done:
+	}
 }
 
 func (c *Client) ReadPump(hub *Hub) {
+	ctx, cancel := context.WithCancel(middleware.WithLogger(context.Background(), c.log()))
 	defer func() {
-		hub.unregister <- c
+		cancel()
+		hub.Unregister(c)
 		c.Conn.Close()
 	}()
+	defer hub.cfg.recoverer().Recover(ctx, "websocket.read")
 	
-	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	if c.inflight == nil {
+		c.inflight = make(chan struct{}, hub.cfg.MaxInflightRequests)
+	}
+	if c.maxMessageSize > 0 {
+		c.Conn.SetReadLimit(c.maxMessageSize)
+	}
+
+	pongWait := hub.cfg.PongWait
+	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.Conn.SetPongHandler(func(string) error {
-		c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.Conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
 	
 	for {
-		var msg Message
-		err := c.Conn.ReadJSON(&msg)
+		frameType, data, err := c.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				fmt.Printf("WebSocket error: %v\n", err)
+				c.log().Warn("websocket read failed", "error", err)
 			}
 			break
 		}
+
+		var msg Message
+		if err := decodeFrame(c.currentCodec(), frameType, data, &msg); err != nil {
+			c.log().Debug("websocket message rejected", "error", err)
+			hub.SendToClient(c, Message{
+				Type:      TypeError,
+				Error:     clientError(c, problem.New(problem.InvalidMessage)),
+				Timestamp: time.Now(),
+			})
+			continue
+		}
+
+		if decision := c.allowMessage(ctx); !decision.Allowed {
+			retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+			hub.SendToClient(c, Message{
+				Type:      TypeError,
+				ID:        msg.ID,
+				Error:     clientError(c, problem.New(problem.RateLimited, max(retryAfter, 1))),
+				Timestamp: time.Now(),
+			})
+			continue
+		}
+
+		switch msg.Type {
+		case TypeRequest:
+			hub.dispatch(ctx, c, msg)
+			continue
+		case TypeDirect:
+			hub.sendDirect(c, msg)
+			continue
+		case TypeSubscribe, TypeUnsubscribe:
+			hub.requestSubscription(c, msg)
+			continue
+		}
 		
 		msg.Timestamp = time.Now()
 		
@@ -179,37 +639,57 @@ func (c *Client) ReadPump(hub *Hub) {
 			Timestamp: time.Now(),
 		}
 		
-		hub.BroadcastMessage(response)
+		hub.BroadcastMessage(ctx, response)
 	}
 }
 
 func (c *Client) WritePump() {
-	ticker := time.NewTicker(54 * time.Second)
+	cfg := c.config()
+	ticker := time.NewTicker(cfg.PingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.Conn.Close()
 	}()
+	defer cfg.recoverer().Recover(middleware.WithLogger(context.Background(), c.log()), "websocket.write")
 	
+	batch := make([]Message, 0, cfg.MaxBatchSize)
 	for {
 		select {
 		case message, ok := <-c.Send:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.Conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
 			if !ok {
 				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
 			
-			data, err := json.Marshal(message)
-			if err != nil {
+			// Под нагрузкой в очереди копятся сообщения: забираем всё, что
+			// уже есть, и отправляем меньшим числом кадров.
+			batch = append(batch[:0], message)
+			closed := false
+		drain:
+			for len(batch) < cfg.MaxBatchSize {
+				select {
+				case next, ok := <-c.Send:
+					if !ok {
+						closed = true
+						break drain
+					}
+					batch = append(batch, next)
+				default:
+					break drain
+				}
+			}
+
+			if err := c.writeBatch(batch); err != nil {
 				return
 			}
-			
-			if err := c.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			if closed {
+				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
 			
 		case <-ticker.C:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.Conn.SetWriteDeadline(time.Now().Add(cfg.WriteWait))
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -217,3 +697,55 @@ func (c *Client) WritePump() {
 	}
 }
 
+func (c *Client) SetCodec(codec Codec) {
+	c.codec = codec
+}
+
+// SetLanguage задаёт язык служебных сообщений клиенту (welcome, shutdown);
+// обычно — язык запроса на апгрейд.
+func (c *Client) SetLanguage(lang string) {
+	c.lang = lang
+}
+
+func (c *Client) Language() string {
+	if c.lang == "" {
+		return i18n.DefaultLanguage
+	}
+	return c.lang
+}
+
+func (c *Client) currentCodec() Codec {
+	if c.codec == nil {
+		return legacyCodec
+	}
+	return c.codec
+}
+
+// writeBatch склеивает сообщения в один кадр типа batch, если клиент выбрал
+// подпротокол; клиенты без подпротокола получают кадр на каждое сообщение.
+func (c *Client) writeBatch(batch []Message) error {
+	codec := c.currentCodec()
+	threshold := c.config().CompressionThreshold
+
+	if len(batch) > 1 && codec.Subprotocol() != "" {
+		batch = []Message{{
+			Type:      TypeBatch,
+			Data:      batch,
+			Timestamp: time.Now(),
+		}}
+	}
+
+	for _, message := range batch {
+		data, err := codec.Encode(message)
+		if err != nil {
+			c.log().Error("websocket encode failed", "type", message.Type, "error", err)
+			continue
+		}
+
+		c.Conn.EnableWriteCompression(len(data) >= threshold)
+		if err := c.Conn.WriteMessage(codec.FrameType(), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"go-showcase/middleware"
+)
+
+// newTestHub запускает хаб без вывода в лог. Recoverer хаба возвращается,
+// чтобы тест мог убедиться, что цикл Run ни разу не паниковал: паника
+// (например, повторное закрытие client.Send) была бы молча перехвачена.
+func newTestHub(t *testing.T, cfg HubConfig) (*Hub, *middleware.Recoverer) {
+	t.Helper()
+	rc := middleware.NewRecoverer(nil)
+	cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg.Recoverer = rc
+	h := NewHubWithConfig(cfg)
+	go h.Run()
+	t.Cleanup(h.Shutdown)
+	return h, rc
+}
+
+func assertNoPanics(t *testing.T, rc *middleware.Recoverer) {
+	t.Helper()
+	if n := rc.Stats()["total"].(uint64); n != 0 {
+		t.Fatalf("hub recovered %d panics", n)
+	}
+}
+
+// drain читает очередь клиента до закрытия и сообщает, когда хаб её закрыл.
+func drain(c *Client) <-chan struct{} {
+	closed := make(chan struct{})
+	go func() {
+		for range c.Send {
+		}
+		close(closed)
+	}()
+	return closed
+}
+
+// waitClosed вызывается и из горутин теста, поэтому не останавливает его.
+func waitClosed(t *testing.T, c *Client, closed <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Errorf("send queue of %s was never closed", c.ID)
+	}
+}
+
+func TestHubRegisterUnregisterStress(t *testing.T) {
+	h, rc := newTestHub(t, HubConfig{})
+	ctx := context.Background()
+
+	const clients = 2000
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Общие ID: у одного клиента бывает несколько соединений.
+			c := h.NewClient(fmt.Sprintf("client-%d", i%100), nil)
+			closed := drain(c)
+
+			h.Register(c)
+			h.BroadcastMessage(ctx, Message{Type: "test"})
+			h.SendTo(c.ID, Message{Type: TypeDirect})
+			h.Unregister(c)
+			waitClosed(t, c, closed)
+		}(i)
+	}
+	wg.Wait()
+
+	stats := h.GetStats()
+	if stats["total_clients"] != 0 || stats["unique_clients"] != 0 {
+		t.Fatalf("clients left after unregister: %v", stats)
+	}
+	if presence := h.Presence(); len(presence) != 0 {
+		t.Fatalf("presence after unregister: %v", presence)
+	}
+	assertNoPanics(t, rc)
+}
+
+func TestHubDoubleUnregister(t *testing.T) {
+	h, rc := newTestHub(t, HubConfig{})
+
+	c := h.NewClient("twice", nil)
+	closed := drain(c)
+	h.Register(c)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.Unregister(c)
+		}()
+	}
+	wg.Wait()
+	waitClosed(t, c, closed)
+
+	h.Unregister(c)
+	assertNoPanics(t, rc)
+}
+
+// TestHubEvictionRacesShutdown забивает очереди клиентов, которые ничего не
+// читают, так что хаб вытесняет их, пока параллельно идут отключения и
+// Shutdown. Каждая очередь должна быть закрыта ровно один раз.
+func TestHubEvictionRacesShutdown(t *testing.T) {
+	for _, policy := range []BackpressurePolicy{Disconnect, DropOldest, Block} {
+		t.Run(policy.String(), func(t *testing.T) {
+			h, rc := newTestHub(t, HubConfig{
+				SendBufferSize: 2,
+				Backpressure:   policy,
+				BlockTimeout:   time.Millisecond,
+			})
+			ctx := context.Background()
+
+			const clients = 500
+			all := make([]*Client, clients)
+			for i := range all {
+				all[i] = h.NewClient(fmt.Sprintf("slow-%d", i), nil)
+				h.Register(all[i])
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						h.BroadcastMessage(ctx, Message{Type: "flood"})
+					}
+				}()
+			}
+			for i := 0; i < clients; i += 3 {
+				wg.Add(1)
+				go func(c *Client) {
+					defer wg.Done()
+					h.Unregister(c)
+				}(all[i])
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				time.Sleep(time.Millisecond)
+				h.Shutdown()
+			}()
+			wg.Wait()
+
+			// Читаем очереди только теперь: до Shutdown клиенты были медленными.
+			for _, c := range all {
+				waitClosed(t, c, drain(c))
+			}
+
+			// После остановки методы хаба не блокируются, а новый клиент
+			// сразу получает закрытую очередь.
+			late := h.NewClient("late", nil)
+			h.Register(late)
+			waitClosed(t, late, drain(late))
+			h.Unregister(late)
+			h.BroadcastMessage(ctx, Message{Type: "after"})
+			h.Shutdown()
+
+			assertNoPanics(t, rc)
+		})
+	}
+}
+
+// TestHubErrorsAreLocalized: ошибки хаба приходят с кодом из каталога
+// problem и текстом на языке, выбранном при подключении.
+func TestHubErrorsAreLocalized(t *testing.T) {
+	h, rc := newTestHub(t, HubConfig{})
+
+	for _, tt := range []struct {
+		lang, message string
+	}{
+		{"en", `Client "bob" is not connected`},
+		{"ru", `Клиент "bob" не подключён`},
+	} {
+		c := h.NewClient("alice-"+tt.lang, nil)
+		c.SetLanguage(tt.lang)
+		h.Register(c)
+		expect(t, c, "welcome")
+
+		h.sendDirect(c, Message{Type: TypeDirect, ID: "1", To: "bob"})
+		msg := expect(t, c, TypeError)
+		if msg.Error == nil || msg.Error.Code != "recipient_offline" || msg.Error.Message != tt.message {
+			t.Errorf("%s: got error %+v, want recipient_offline %q", tt.lang, msg.Error, tt.message)
+		}
+
+		h.requestSubscription(c, Message{Type: TypeSubscribe, ID: "2", Data: map[string]interface{}{"topic": "nope"}})
+		if msg := expect(t, c, TypeError); msg.Error == nil || msg.Error.Code != "unknown_topic" {
+			t.Errorf("%s: got error %+v, want unknown_topic", tt.lang, msg.Error)
+		}
+	}
+	assertNoPanics(t, rc)
+}
+
+// TestHubBlockTimeoutIsShared: политика Block ждёт медленных клиентов один
+// общий BlockTimeout на рассылку, а не по BlockTimeout на каждого, иначе
+// Run (а с ним регистрация и статистика) стоял бы N×BlockTimeout.
+func TestHubBlockTimeoutIsShared(t *testing.T) {
+	const (
+		slow         = 20
+		blockTimeout = 50 * time.Millisecond
+	)
+	h, rc := newTestHub(t, HubConfig{
+		SendBufferSize: 1,
+		Backpressure:   Block,
+		BlockTimeout:   blockTimeout,
+	})
+	ctx := context.Background()
+
+	clients := make([]*Client, slow)
+	for i := range clients {
+		clients[i] = h.NewClient(fmt.Sprintf("slow-%d", i), nil)
+		h.Register(clients[i]) // welcome занимает всю очередь
+	}
+
+	start := time.Now()
+	h.BroadcastMessage(ctx, Message{Type: "flood"})
+	for h.GetStats()["evicted_clients"] != uint64(slow) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("slow clients were not evicted: %v", h.GetStats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed > 5*blockTimeout {
+		t.Fatalf("hub stalled for %v with %d slow clients, BlockTimeout %v", elapsed, slow, blockTimeout)
+	}
+	for _, c := range clients {
+		waitClosed(t, c, drain(c))
+	}
+	assertNoPanics(t, rc)
+}
@@ -0,0 +1,182 @@
+package websocket
+
+import (
+	"sort"
+	"time"
+
+	"go-showcase/problem"
+)
+
+const (
+	TypeDirect      = "direct"
+	TypeSubscribe   = "subscribe"
+	TypeUnsubscribe = "unsubscribe"
+	TypeSubscribed  = "subscribed"
+	TypePresence    = "presence"
+)
+
+const TopicPresence = "presence"
+
+const (
+	PresenceJoined = "joined"
+	PresenceLeft   = "left"
+)
+
+var knownTopics = map[string]bool{
+	TopicPresence: true,
+}
+
+type PresenceInfo struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name,omitempty"`
+	Authenticated bool      `json:"authenticated"`
+	Connections   int       `json:"connections"`
+	ConnectedAt   time.Time `json:"connected_at"`
+}
+
+type subscription struct {
+	client *Client
+	topic  string
+	id     string
+	on     bool
+}
+
+// Presence возвращает список клиентов онлайн: по одной записи на ID,
+// сколько бы соединений у него ни было.
+func (h *Hub) Presence() []PresenceInfo {
+	reply := make(chan []PresenceInfo, 1)
+	select {
+	case h.presence <- reply:
+		return <-reply
+	case <-h.done:
+		return []PresenceInfo{}
+	}
+}
+
+func (h *Hub) sendDirect(from *Client, msg Message) {
+	if msg.To == "" {
+		h.SendToClient(from, Message{
+			Type:      TypeError,
+			ID:        msg.ID,
+			Error:     clientError(from, problem.New(problem.InvalidRequest, "to")),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	select {
+	case h.direct <- directMessage{
+		to:   msg.To,
+		from: from,
+		msg: Message{
+			Type:      TypeDirect,
+			ID:        msg.ID,
+			From:      from.ID,
+			To:        msg.To,
+			Data:      msg.Data,
+			Timestamp: time.Now(),
+		},
+	}:
+	case <-h.done:
+	}
+}
+
+func (h *Hub) requestSubscription(c *Client, msg Message) {
+	topic := ""
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		topic, _ = data["topic"].(string)
+	}
+
+	if !knownTopics[topic] {
+		h.SendToClient(c, Message{
+			Type:      TypeError,
+			ID:        msg.ID,
+			Error:     clientError(c, problem.New(problem.UnknownTopic, topic)),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	select {
+	case h.subscribe <- subscription{client: c, topic: topic, id: msg.ID, on: msg.Type == TypeSubscribe}:
+	case <-h.done:
+	}
+}
+
+func (h *Hub) updateSubscription(sub subscription) {
+	c := sub.client
+	if !h.clients[c] {
+		return
+	}
+
+	if !sub.on {
+		delete(c.topics, sub.topic)
+		delete(h.subscribers[sub.topic], c)
+		return
+	}
+
+	if c.topics == nil {
+		c.topics = make(map[string]bool)
+	}
+	c.topics[sub.topic] = true
+	if h.subscribers[sub.topic] == nil {
+		h.subscribers[sub.topic] = make(map[*Client]bool)
+	}
+	h.subscribers[sub.topic][c] = true
+
+	ack := map[string]interface{}{"topic": sub.topic}
+	if sub.topic == TopicPresence {
+		ack["clients"] = h.presenceList()
+	}
+	h.deliver(c, Message{
+		Type:      TypeSubscribed,
+		ID:        sub.id,
+		Data:      ack,
+		Timestamp: time.Now(),
+	})
+}
+
+func (h *Hub) publishPresence(event string, c *Client) {
+	if h.closing {
+		return
+	}
+
+	info := PresenceInfo{ID: c.ID, Connections: len(h.byID[c.ID]), ConnectedAt: c.connectedAt}
+	if c.Principal != nil {
+		info.Name = c.Principal.Name
+		info.Authenticated = true
+	}
+
+	msg := Message{
+		Type: TypePresence,
+		Data: map[string]interface{}{
+			"event":  event,
+			"client": info,
+		},
+		Timestamp: time.Now(),
+	}
+	h.shareBlockTimeout(func() {
+		for subscriber := range h.subscribers[TopicPresence] {
+			h.deliver(subscriber, msg)
+		}
+	})
+}
+
+func (h *Hub) presenceList() []PresenceInfo {
+	list := make([]PresenceInfo, 0, len(h.byID))
+	for id, connections := range h.byID {
+		info := PresenceInfo{ID: id, Connections: len(connections)}
+		for c := range connections {
+			if c.Principal != nil {
+				info.Name = c.Principal.Name
+				info.Authenticated = true
+			}
+			if info.ConnectedAt.IsZero() || c.connectedAt.Before(info.ConnectedAt) {
+				info.ConnectedAt = c.connectedAt
+			}
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
@@ -0,0 +1,299 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisBroker — брокер поверх PUBLISH/SUBSCRIBE протокола Redis (RESP).
+// Работает с Redis, KeyDB, Valkey и любым сервером, понимающим эти команды.
+// Подписка переподключается сама; публикация переподключается при ошибке.
+type RedisBroker struct {
+	addr    string
+	channel string
+	dialer  net.Dialer
+
+	pubMu   sync.Mutex
+	pubConn net.Conn
+	pubR    *bufio.Reader
+
+	mu       sync.Mutex
+	nextID   int
+	handlers map[int]func(Envelope)
+	subConn  net.Conn
+	started  bool
+	closed   chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewRedisBroker(addr, channel string) *RedisBroker {
+	return &RedisBroker{
+		addr:     addr,
+		channel:  channel,
+		dialer:   net.Dialer{Timeout: 5 * time.Second},
+		handlers: make(map[int]func(Envelope)),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, env Envelope) error {
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	b.pubMu.Lock()
+	defer b.pubMu.Unlock()
+
+	// Одна повторная попытка: соединение могло закрыться по таймауту простоя.
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = b.publishLocked(ctx, payload); err == nil {
+			return nil
+		}
+		if b.pubConn != nil {
+			b.pubConn.Close()
+			b.pubConn = nil
+		}
+	}
+	return err
+}
+
+func (b *RedisBroker) publishLocked(ctx context.Context, payload []byte) error {
+	if b.pubConn == nil {
+		conn, err := b.dialer.DialContext(ctx, "tcp", b.addr)
+		if err != nil {
+			return err
+		}
+		b.pubConn = conn
+		b.pubR = bufio.NewReader(conn)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		b.pubConn.SetDeadline(deadline)
+	} else {
+		b.pubConn.SetDeadline(time.Time{})
+	}
+
+	if _, err := b.pubConn.Write(respCommand("PUBLISH", b.channel, string(payload))); err != nil {
+		return err
+	}
+	reply, err := readRESP(b.pubR)
+	if err != nil {
+		return err
+	}
+	if respErr, ok := reply.(respError); ok {
+		return respErr
+	}
+	return nil
+}
+
+func (b *RedisBroker) Subscribe(handler func(Envelope)) (func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	select {
+	case <-b.closed:
+		return nil, errors.New("redis broker is closed")
+	default:
+	}
+
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+
+	if !b.started {
+		b.started = true
+		b.wg.Add(1)
+		go b.subscribeLoop()
+	}
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.handlers, id)
+	}, nil
+}
+
+func (b *RedisBroker) Close() error {
+	b.mu.Lock()
+	select {
+	case <-b.closed:
+		b.mu.Unlock()
+		return nil
+	default:
+	}
+	close(b.closed)
+	if b.subConn != nil {
+		b.subConn.Close()
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+
+	b.pubMu.Lock()
+	defer b.pubMu.Unlock()
+	if b.pubConn != nil {
+		b.pubConn.Close()
+		b.pubConn = nil
+	}
+	return nil
+}
+
+func (b *RedisBroker) subscribeLoop() {
+	defer b.wg.Done()
+
+	backoff := 100 * time.Millisecond
+	for {
+		err := b.subscribeOnce()
+
+		select {
+		case <-b.closed:
+			return
+		default:
+		}
+
+		slog.Warn("websocket redis subscription lost", "error", err, "retry_in", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-b.closed:
+			return
+		}
+		if backoff < 5*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (b *RedisBroker) subscribeOnce() error {
+	conn, err := b.dialer.Dial("tcp", b.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	b.mu.Lock()
+	select {
+	case <-b.closed:
+		b.mu.Unlock()
+		return nil
+	default:
+	}
+	b.subConn = conn
+	b.mu.Unlock()
+
+	if _, err := conn.Write(respCommand("SUBSCRIBE", b.channel)); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := readRESP(r)
+		if err != nil {
+			return err
+		}
+
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 3 {
+			continue
+		}
+		if kind, _ := parts[0].(string); kind != "message" {
+			continue
+		}
+		payload, _ := parts[2].(string)
+
+		var env Envelope
+		if err := json.Unmarshal([]byte(payload), &env); err != nil {
+			continue
+		}
+
+		b.mu.Lock()
+		handlers := make([]func(Envelope), 0, len(b.handlers))
+		for _, handler := range b.handlers {
+			handlers = append(handlers, handler)
+		}
+		b.mu.Unlock()
+
+		for _, handler := range handlers {
+			handler(env)
+		}
+	}
+}
+
+type respError string
+
+func (e respError) Error() string {
+	return "redis: " + string(e)
+}
+
+func respCommand(args ...string) []byte {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	return buf
+}
+
+// readRESP читает одно значение RESP2: простые строки и bulk-строки
+// возвращаются как string, целые — как int64, массивы — как []interface{}.
+func readRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return respError(body), nil
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %q", kind)
+	}
+}
@@ -0,0 +1,265 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// respServer — минимальная замена Redis внутри теста: понимает SUBSCRIBE и
+// PUBLISH, умеет рвать подписки и доставлять каждое сообщение дважды.
+type respServer struct {
+	ln net.Listener
+
+	mu        sync.Mutex
+	subs      map[net.Conn]string
+	duplicate bool
+}
+
+func newRESPServer(t *testing.T) *respServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &respServer{ln: ln, subs: make(map[net.Conn]string)}
+	go s.serve()
+	t.Cleanup(func() {
+		ln.Close()
+		s.dropSubscribers()
+	})
+	return s
+}
+
+func (s *respServer) addr() string { return s.ln.Addr().String() }
+
+func (s *respServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *respServer) handle(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		v, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		items, _ := v.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		if len(args) == 0 {
+			return
+		}
+
+		s.mu.Lock()
+		switch {
+		case strings.EqualFold(args[0], "SUBSCRIBE") && len(args) == 2:
+			s.subs[conn] = args[1]
+			conn.Write(respCommand("subscribe", args[1], "1"))
+		case strings.EqualFold(args[0], "PUBLISH") && len(args) == 3:
+			n := s.publishLocked(args[1], args[2])
+			conn.Write([]byte(":" + strconv.Itoa(n) + "\r\n"))
+		default:
+			conn.Write([]byte("-ERR unknown command\r\n"))
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *respServer) publishLocked(channel, payload string) int {
+	frame := respCommand("message", channel, payload)
+	if s.duplicate {
+		frame = append(frame, frame...)
+	}
+	n := 0
+	for conn, ch := range s.subs {
+		if ch == channel {
+			conn.Write(frame)
+			n++
+		}
+	}
+	return n
+}
+
+func (s *respServer) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+func (s *respServer) setDuplicate(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.duplicate = on
+}
+
+// dropSubscribers закрывает соединения подписчиков, как при рестарте Redis.
+func (s *respServer) dropSubscribers() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.subs {
+		conn.Close()
+		delete(s.subs, conn)
+	}
+}
+
+func (s *respServer) waitSubscribers(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for s.subscribers() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("want %d subscribers, have %d", n, s.subscribers())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// newBrokeredHub запускает хаб с RedisBroker и одним клиентом.
+func newBrokeredHub(t *testing.T, addr, id string) (*Hub, *Client) {
+	t.Helper()
+	h, rc := newTestHub(t, HubConfig{})
+	broker := NewRedisBroker(addr, "test")
+	if err := h.UseBroker(broker); err != nil {
+		t.Fatal(err)
+	}
+	// Cleanup выполняются в обратном порядке: брокер закроется после
+	// остановки хаба.
+	t.Cleanup(func() { broker.Close() })
+	t.Cleanup(func() { assertNoPanics(t, rc) })
+
+	c := h.NewClient(id, nil)
+	h.Register(c)
+	expect(t, c, "welcome")
+	return h, c
+}
+
+func expect(t *testing.T, c *Client, typ string) Message {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-c.Send:
+			if msg.Type == typ {
+				return msg
+			}
+		case <-timeout:
+			t.Fatalf("%s: no %q message", c.ID, typ)
+		}
+	}
+}
+
+func expectNone(t *testing.T, c *Client, typ string) {
+	t.Helper()
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case msg := <-c.Send:
+			if msg.Type == typ {
+				t.Fatalf("%s: unexpected %q message: %+v", c.ID, typ, msg)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestRedisBrokerFanOut(t *testing.T) {
+	srv := newRESPServer(t)
+	a, clientA := newBrokeredHub(t, srv.addr(), "a")
+	b, clientB := newBrokeredHub(t, srv.addr(), "b")
+	srv.waitSubscribers(t, 2)
+	ctx := context.Background()
+
+	a.BroadcastMessage(ctx, Message{Type: "from-a"})
+	expect(t, clientA, "from-a")
+	expect(t, clientB, "from-a")
+
+	b.BroadcastMessage(ctx, Message{Type: "from-b"})
+	expect(t, clientA, "from-b")
+	expect(t, clientB, "from-b")
+
+	// Эхо собственных сообщений отброшено: каждое пришло один раз.
+	expectNone(t, clientA, "from-a")
+	expectNone(t, clientB, "from-b")
+}
+
+func TestRedisBrokerDeduplicates(t *testing.T) {
+	srv := newRESPServer(t)
+	a, clientA := newBrokeredHub(t, srv.addr(), "a")
+	_, clientB := newBrokeredHub(t, srv.addr(), "b")
+	srv.waitSubscribers(t, 2)
+	srv.setDuplicate(true)
+
+	for i := 0; i < 3; i++ {
+		a.BroadcastMessage(context.Background(), Message{Type: "dup", ID: strconv.Itoa(i)})
+	}
+	for i := 0; i < 3; i++ {
+		if msg := expect(t, clientB, "dup"); msg.ID != strconv.Itoa(i) {
+			t.Fatalf("b: got message %s, want %d", msg.ID, i)
+		}
+		expect(t, clientA, "dup")
+	}
+	expectNone(t, clientA, "dup")
+	expectNone(t, clientB, "dup")
+}
+
+func TestRedisBrokerSubscriptionDrop(t *testing.T) {
+	srv := newRESPServer(t)
+	a, clientA := newBrokeredHub(t, srv.addr(), "a")
+	_, clientB := newBrokeredHub(t, srv.addr(), "b")
+	srv.waitSubscribers(t, 2)
+	ctx := context.Background()
+
+	// Пока подписки переподключаются, PUBLISH проходит, но эха нет:
+	// локальный клиент всё равно должен получить сообщение.
+	srv.dropSubscribers()
+	a.BroadcastMessage(ctx, Message{Type: "during-drop"})
+	expect(t, clientA, "during-drop")
+
+	srv.waitSubscribers(t, 2)
+	a.BroadcastMessage(ctx, Message{Type: "after-drop"})
+	expect(t, clientA, "after-drop")
+	expect(t, clientB, "after-drop")
+	expectNone(t, clientA, "after-drop")
+}
+
+func TestSeqWindow(t *testing.T) {
+	var w seqWindow
+	steps := []struct {
+		seq  uint64
+		want bool
+	}{
+		{1, true},
+		{3, true},
+		{2, true},  // не по порядку, но внутри окна
+		{3, false}, // повтор
+		{1, false},
+		{1 + dedupWindow + 5, true},
+		{5, false}, // старше окна
+	}
+	for _, step := range steps {
+		if got := w.accept(step.seq); got != step.want {
+			t.Fatalf("accept(%d) = %v, want %v", step.seq, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go-showcase/i18n"
+	"go-showcase/middleware"
+	"go-showcase/problem"
+	"go-showcase/tracing"
+)
+
+const (
+	TypeRequest  = "request"
+	TypeResponse = "response"
+	TypeError    = "error"
+)
+
+type RPCError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Status  int         `json:"status,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// RPCHandler обрабатывает один запрос {type:"request", id, method, params}.
+// Возвращённый результат уходит клиенту в поле data ответа с тем же id.
+type RPCHandler func(ctx context.Context, client *Client, params json.RawMessage) (interface{}, *RPCError)
+
+func (h *Hub) HandleRPC(method string, handler RPCHandler) {
+	h.rpcMu.Lock()
+	defer h.rpcMu.Unlock()
+	h.rpc[method] = handler
+}
+
+func (h *Hub) rpcHandler(method string) (RPCHandler, bool) {
+	h.rpcMu.RLock()
+	defer h.rpcMu.RUnlock()
+	handler, ok := h.rpc[method]
+	return handler, ok
+}
+
+// dispatch вызывается из ReadPump. Каждый запрос выполняется в отдельной
+// горутине, но одновременно у соединения не больше cap(c.inflight) запросов.
+func (h *Hub) dispatch(ctx context.Context, c *Client, req Message) {
+	if req.ID == "" {
+		h.SendToClient(c, errorResponse(req, clientError(c, problem.New(problem.InvalidRequest, "id"))))
+		return
+	}
+
+	handler, ok := h.rpcHandler(req.Method)
+	if !ok {
+		h.SendToClient(c, errorResponse(req, clientError(c, problem.New(problem.MethodNotFound, req.Method))))
+		return
+	}
+
+	select {
+	case c.inflight <- struct{}{}:
+	default:
+		h.SendToClient(c, errorResponse(req, clientError(c, problem.New(problem.TooManyRequests, cap(c.inflight)))))
+		return
+	}
+
+	go func() {
+		defer func() { <-c.inflight }()
+
+		callCtx, cancel := context.WithTimeout(ctx, h.cfg.RPCTimeout)
+		defer cancel()
+
+		// Каждый вызов — отдельная трасса: соединение живёт слишком долго,
+		// чтобы держать все вызовы в трассе запроса на апгрейд.
+		callCtx, span := tracing.Start(callCtx, "websocket.rpc "+req.Method, tracing.WithKind(tracing.KindServer))
+		defer span.End()
+		span.SetAttribute("rpc.system", "websocket")
+		span.SetAttribute("rpc.method", req.Method)
+		span.SetAttribute("websocket.client_id", c.ID)
+
+		logger := c.log().With("rpc_id", req.ID, "rpc_method", req.Method)
+		if sc := span.SpanContext(); sc.IsValid() {
+			logger = logger.With("trace_id", sc.TraceID.String(), "span_id", sc.SpanID.String())
+		}
+		callCtx = middleware.WithLogger(callCtx, logger)
+
+		defer func() {
+			if p := recover(); p != nil {
+				h.cfg.recoverer().HandlePanic(callCtx, "websocket.rpc", p)
+				span.SetStatus(tracing.StatusError, "panic")
+				h.SendToClient(c, errorResponse(req, clientError(c, problem.New(problem.Internal))))
+			}
+		}()
+
+		result, rpcErr := handler(callCtx, c, req.Params)
+		if rpcErr != nil {
+			span.SetStatus(tracing.StatusError, rpcErr.Error())
+			h.SendToClient(c, errorResponse(req, rpcErr))
+			return
+		}
+
+		h.SendToClient(c, Message{
+			Type:      TypeResponse,
+			ID:        req.ID,
+			Method:    req.Method,
+			Data:      result,
+			Timestamp: time.Now(),
+		})
+	}()
+}
+
+// clientError — ошибка из каталога problem с текстом на языке клиента.
+func clientError(c *Client, err *problem.Error) *RPCError {
+	p := err.Problem(i18n.Lang(c.Language()))
+	message := p.Detail
+	if message == "" {
+		message = p.Title
+	}
+	return &RPCError{Code: string(p.Code), Message: message, Status: p.Status}
+}
+
+func errorResponse(req Message, err *RPCError) Message {
+	return Message{
+		Type:      TypeError,
+		ID:        req.ID,
+		Method:    req.Method,
+		Error:     err,
+		Timestamp: time.Now(),
+	}
+}